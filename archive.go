@@ -0,0 +1,65 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+)
+
+// Open zip archive object as read-only file system, exposing archive members
+// as files. Archive is read through io.ReaderAt if the file supports it,
+// otherwise the archive is read into memory.
+//
+// The returned file system implements io.Closer, close it to release the
+// archive object.
+func OpenArchive(fsys fs.FS, path string) (fs.FS, error) {
+	fd, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.ReaderAt
+	var size int64
+
+	if ra, ok := fd.(io.ReaderAt); ok {
+		fi, err := fd.Stat()
+		if err != nil {
+			fd.Close()
+			return nil, err
+		}
+		r, size = ra, fi.Size()
+	} else {
+		buf, err := io.ReadAll(fd)
+		if err != nil {
+			fd.Close()
+			return nil, err
+		}
+		r, size = bytes.NewReader(buf), int64(len(buf))
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		fd.Close()
+		return nil, &fs.PathError{
+			Op:   "archive",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	return archive{Reader: zr, Closer: fd}, nil
+}
+
+type archive struct {
+	*zip.Reader
+	io.Closer
+}
@@ -9,6 +9,8 @@
 package stream
 
 import (
+	"net/url"
+	"reflect"
 	"strings"
 	"time"
 
@@ -24,13 +26,20 @@ type codec[T any] struct {
 	w optics.Isomorphism[T, s3.PutObjectInput]
 	r optics.Isomorphism[T, s3.GetObjectOutput]
 	s optics.Lens[T, string]
+	t []codecTag[T]
 }
 
-func newCodec[T any]() *codec[T] {
+// field of struct tagged with `tag:"..."`, it is mapped to the object tag
+type codecTag[T any] struct {
+	key  string
+	lens optics.Lens[T, string]
+}
+
+func newCodec[T any](delim string) *codec[T] {
 	c := &codec[T]{
-		h: isomorphism[T, s3.HeadObjectOutput](),
-		w: isomorphism[T, s3.PutObjectInput](),
-		r: isomorphism[T, s3.GetObjectOutput](),
+		h: isomorphism[T, s3.HeadObjectOutput](delim),
+		w: isomorphism[T, s3.PutObjectInput](delim),
+		r: isomorphism[T, s3.GetObjectOutput](delim),
 	}
 
 	ts := hseq.New[T]()
@@ -38,6 +47,12 @@ func newCodec[T any]() *codec[T] {
 		c.s = optics.NewLens[T, string](t)
 	}
 
+	for _, t := range ts {
+		if key := tagKey(t); key != "" && t.Type.Kind() == reflect.String {
+			c.t = append(c.t, codecTag[T]{key: key, lens: optics.NewLens[T, string](t)})
+		}
+	}
+
 	return c
 }
 
@@ -45,8 +60,41 @@ func (c *codec[T]) DecodeHeadOutput(s *s3.HeadObjectOutput, t *T) { c.h.Inverse(
 func (c *codec[T]) EncodePutInput(t *T, s *s3.PutObjectInput)     { c.w.Forward(t, s) }
 func (c *codec[T]) DecodeGetOutput(s *s3.GetObjectOutput, t *T)   { c.r.Inverse(s, t) }
 
-// codec for category S to T
-func isomorphism[T, S any]() optics.Isomorphism[T, S] {
+// HasTagging reports if type T declares object tags
+func (c *codec[T]) HasTagging() bool { return len(c.t) != 0 }
+
+// EncodeTagging encodes tagged fields as URL-encoded key=value pairs
+// of PutObjectInput.Tagging, empty fields are omitted.
+func (c *codec[T]) EncodeTagging(t *T, s *s3.PutObjectInput) {
+	if t == nil || len(c.t) == 0 {
+		return
+	}
+
+	tags := url.Values{}
+	for _, tag := range c.t {
+		if val := tag.lens.Get(t); val != "" {
+			tags.Set(tag.key, val)
+		}
+	}
+
+	if len(tags) != 0 {
+		s.Tagging = aws.String(tags.Encode())
+	}
+}
+
+// DecodeTagging decodes the tag set (e.g. of GetObjectTagging) into tagged fields
+func (c *codec[T]) DecodeTagging(tags []types.Tag, t *T) {
+	for _, tag := range c.t {
+		for _, x := range tags {
+			if aws.ToString(x.Key) == tag.key {
+				tag.lens.Put(t, aws.ToString(x.Value))
+			}
+		}
+	}
+}
+
+// codec for category S to T, the delimiter joins keys of nested structs
+func isomorphism[T, S any](delim string) optics.Isomorphism[T, S] {
 	ts := hseq.New[T]()
 	sq := hseq.New[S]()
 
@@ -55,6 +103,8 @@ func isomorphism[T, S any]() optics.Isomorphism[T, S] {
 		switch t.FieldKey() {
 		case "CacheControl":
 			iso = append(iso, codecString(ts, sq, "CacheControl"))
+		case "ContentDisposition":
+			iso = append(iso, codecString(ts, sq, "ContentDisposition"))
 		case "ContentEncoding":
 			iso = append(iso, codecString(ts, sq, "ContentEncoding"))
 		case "ContentLanguage":
@@ -69,15 +119,54 @@ func isomorphism[T, S any]() optics.Isomorphism[T, S] {
 			iso = append(iso, codecTime(ts, sq, "LastModified"))
 		case "StorageClass":
 			iso = append(iso, codecStorageClass(ts, sq, "StorageClass"))
+		case "ContentLength":
+			// the size is decoded only, uploader defines the length of written object
+			if _, isPut := any(new(S)).(*s3.PutObjectInput); !isPut {
+				iso = append(iso, codecInt64(ts, sq, "ContentLength"))
+			}
 		case "PreSignedUrl":
 		default:
-			iso = append(iso, codecMetadata(t, sq))
+			if tagKey(t) != "" {
+				continue
+			}
+
+			if isNestedStruct(t) {
+				iso = append(iso, codecNested(t, sq, delim)...)
+			} else {
+				iso = append(iso, codecMetadata(t, metadataKey(t), sq))
+			}
 		}
 	}
 
 	return optics.Morphism(iso...)
 }
 
+// named (not embedded) struct field, flattened one level into metadata
+func isNestedStruct[T any](t hseq.Type[T]) bool {
+	return t.Type.Kind() == reflect.Struct && t.Type != reflect.TypeOf(time.Time{})
+}
+
+func codecNested[T, S any](t hseq.Type[T], sq hseq.Seq[S], delim string) []optics.Isomorphism[T, S] {
+	prefix := metadataKey(t)
+
+	iso := []optics.Isomorphism[T, S]{}
+	for i := 0; i < t.Type.NumField(); i++ {
+		f := t.Type.Field(i)
+		if !f.IsExported() || f.Type.Kind() != reflect.String {
+			continue
+		}
+
+		nested := hseq.Type[T]{
+			StructField: f,
+			RootOffs:    t.RootOffs + t.Offset,
+			PureType:    f.Type,
+		}
+		iso = append(iso, codecMetadata(nested, prefix+delim+metadataKey(nested), sq))
+	}
+
+	return iso
+}
+
 func codecString[T, S any](ts hseq.Seq[T], sq hseq.Seq[S], attr string) optics.Isomorphism[T, S] {
 	t, has := hseq.ForNameMaybe(ts, attr)
 	if !has {
@@ -118,6 +207,26 @@ func codecStorageClass[T, S any](ts hseq.Seq[T], sq hseq.Seq[S], attr string) op
 	return optics.Iso(enc, dec)
 }
 
+func codecInt64[T, S any](ts hseq.Seq[T], sq hseq.Seq[S], attr string) optics.Isomorphism[T, S] {
+	t, has := hseq.ForNameMaybe(ts, attr)
+	if !has {
+		return nil
+	}
+
+	s, has := hseq.ForNameMaybe(sq, attr)
+	if !has {
+		return nil
+	}
+
+	dec := optics.BiMap(
+		optics.NewLens[S, *int64](s),
+		aws.ToInt64,
+		aws.Int64,
+	)
+	enc := optics.NewLens[T, int64](t)
+	return optics.Iso(enc, dec)
+}
+
 func codecTime[T, S any](ts hseq.Seq[T], sq hseq.Seq[S], attr string) optics.Isomorphism[T, S] {
 	t, has := hseq.ForNameMaybe(ts, attr)
 	if !has {
@@ -134,12 +243,21 @@ func codecTime[T, S any](ts hseq.Seq[T], sq hseq.Seq[S], attr string) optics.Iso
 	return optics.Iso(enc, dec)
 }
 
-func codecMetadata[T, S any](t hseq.Type[T], sq hseq.Seq[S]) optics.Isomorphism[T, S] {
+func metadataKey[T any](t hseq.Type[T]) string {
 	attr := strings.Split(t.StructField.Tag.Get("hseq"), ",")[0]
 	if attr == "" {
 		attr = t.Name
 	}
 
+	return attr
+}
+
+// the key of object tag, fields tagged as `tag:"environment"` are not metadata
+func tagKey[T any](t hseq.Type[T]) string {
+	return t.StructField.Tag.Get("tag")
+}
+
+func codecMetadata[T, S any](t hseq.Type[T], attr string, sq hseq.Seq[S]) optics.Isomorphism[T, S] {
 	s, has := hseq.ForNameMaybe(sq, "Metadata")
 	if !has {
 		return nil
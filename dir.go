@@ -11,8 +11,9 @@ package stream
 import (
 	"context"
 	"errors"
-
+	"io"
 	"io/fs"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -21,10 +22,17 @@ import (
 
 //------------------------------------------------------------------------------
 
+// the number of attempts to list a page, listing of large prefix is
+// prone to throttling (SlowDown)
+const listingMaxAttempts = 5
+
 // directory descriptor
 type dd[T any] struct {
 	info[T]
-	fs *FileSystem[T]
+	fs  *FileSystem[T]
+	ctx context.Context
+	seq []fs.DirEntry
+	pos int
 }
 
 var (
@@ -38,7 +46,8 @@ func openDir[T any](fsys *FileSystem[T], path string) *dd[T] {
 			path: path,
 			mode: fs.ModeDir,
 		},
-		fs: fsys,
+		fs:  fsys,
+		ctx: fsys.ctx,
 	}
 }
 
@@ -54,44 +63,114 @@ func (dd *dd[T]) Read([]byte) (int, error) {
 
 func (dd *dd[T]) Close() error { return nil }
 
+// ReadDir reads the contents of the directory. If n > 0, ReadDir returns
+// at most n entries, io.EOF is returned when directory is exhausted.
+// If n <= 0, ReadDir returns all remaining entries.
 func (dd *dd[T]) ReadDir(n int) ([]fs.DirEntry, error) {
-	return dd.readAll()
+	if dd.seq == nil {
+		ctx, cancel := dd.fs.timeoutContext(dd.ctx)
+		defer cancel()
+
+		seq, err := dd.readAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		dd.seq = seq
+	}
+
+	tail := dd.seq[dd.pos:]
+	if n <= 0 {
+		dd.pos = len(dd.seq)
+		return tail, nil
+	}
+
+	if len(tail) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(tail) {
+		n = len(tail)
+	}
+	dd.pos += n
+
+	return tail[:n], nil
 }
 
-func (dd *dd[T]) readAll() ([]fs.DirEntry, error) {
+func (dd *dd[T]) readAll(ctx context.Context) ([]fs.DirEntry, error) {
 	seq := make([]fs.DirEntry, 0)
-	req := &s3.ListObjectsV2Input{
-		Bucket:  aws.String(dd.fs.bucket),
-		MaxKeys: aws.Int32(dd.fs.lslimit),
-		Prefix:  dd.s3Key(),
+	err := dd.walk(ctx, func(page []types.Object) error {
+		for _, el := range page {
+			// directory markers (zero-byte "prefix/" objects) are not files
+			if strings.HasSuffix(aws.ToString(el.Key), "/") {
+				continue
+			}
+			seq = append(seq, dd.objectToDirEntry(el))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), dd.fs.timeout)
-	defer cancel()
+	return seq, nil
+}
+
+// walks all objects under the prefix page by page, including directory markers
+func (dd *dd[T]) walk(ctx context.Context, f func([]types.Object) error) error {
+	req := &s3.ListObjectsV2Input{
+		Bucket:              aws.String(dd.fs.bucket),
+		ExpectedBucketOwner: dd.fs.expectedBucketOwner(),
+		MaxKeys:             aws.Int32(dd.fs.lslimit),
+		Prefix:              dd.s3Key(),
+	}
 
 	for {
-		val, err := dd.fs.api.ListObjectsV2(ctx, req)
+		val, err := dd.listPage(ctx, req)
 		if err != nil {
-			return nil, &fs.PathError{
+			return &fs.PathError{
 				Op:   "readdir",
 				Path: dd.path,
 				Err:  err,
 			}
 		}
 
-		for _, el := range val.Contents {
-			seq = append(seq, dd.objectToDirEntry(el))
+		if err := f(val.Contents); err != nil {
+			return err
 		}
 
 		cnt := int(aws.ToInt32(val.KeyCount))
 		if cnt == 0 || val.NextContinuationToken == nil {
-			return seq, nil
+			return nil
 		}
 
 		req.StartAfter = val.Contents[cnt-1].Key
 	}
 }
 
+// lists a page of objects bounded by the I/O timeout
+func (dd *dd[T]) listPage(ctx context.Context, req *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	ctx, cancel := dd.fs.timeoutContext(ctx)
+	defer cancel()
+
+	return dd.listObjects(ctx, req)
+}
+
+// lists a page of objects, throttled requests are retried by the retryer
+// of S3 client (jittered exponential backoff) with extra attempts
+func (dd *dd[T]) listObjects(ctx context.Context, req *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	dd.fs.debug("ListObjectsV2", req.Prefix)
+	return dd.fs.api.ListObjectsV2(ctx, req, append(dd.fs.regionOptions(req.Prefix), listingRetryOptions)...)
+}
+
+// raises max attempts of the retryer unless it is configured with more
+func listingRetryOptions(o *s3.Options) {
+	if o.Retryer == nil || o.Retryer.MaxAttempts() >= listingMaxAttempts {
+		return
+	}
+
+	o.RetryMaxAttempts = listingMaxAttempts
+}
+
 func (dd *dd[T]) objectToDirEntry(t types.Object) fs.DirEntry {
 	// Note: file system requires a strict hierarchical division on files and dirs.
 	//       It is assumed by fs.FS implementations (e.g. WalkDir) and also requires
@@ -0,0 +1,147 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+var (
+	// Access to the object is denied by S3 (e.g. credentials permit listing
+	// but not reading of objects). The error wraps the original AWS error.
+	ErrAccessDenied = errors.New("access denied")
+
+	// Path component is not a directory (e.g. local file system fails to
+	// create /a/b if /a is a file). The error wraps fs.ErrInvalid.
+	ErrNotDir = fmt.Errorf("not a directory: %w", fs.ErrInvalid)
+
+	// Range of the read is ignored by S3 because the object has changed
+	// since the If-Range condition (e.g. ETag) was obtained. The client
+	// shall restart the read from the beginning.
+	ErrRangeIgnored = errors.New("range ignored, object has changed")
+
+	// Object is not modified since the known version (ETag), the client
+	// shall use its cached content.
+	ErrNotModified = errors.New("not modified")
+
+	// Object exceeds the size limit configured by WithMaxObjectSize.
+	ErrObjectTooLarge = errors.New("object is too large")
+
+	// No space left at the file system (e.g. disk is full or quota exceeded).
+	// The error wraps the original OS error.
+	ErrNoSpace = errors.New("no space left")
+
+	// Checksum of the read bytes does not match the checksum reported by S3,
+	// see WithChecksumValidation.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// Batch is aborted due to failure of another item, the item is either
+	// rolled back or not attempted, see WriteBatch.
+	ErrBatchAborted = errors.New("batch aborted")
+)
+
+// BatchError reports partial failure of batch operation (e.g. WriteBatch,
+// RemoveAll), it maps the path of each failed item to its error. errors.Is
+// and errors.As are delegated to failures, starting from the first one.
+type BatchError struct {
+	// Errors of failed items by path
+	Failed map[string]error
+
+	// Number of items processed successfully
+	Succeeded int
+
+	// Errors of rollback by path, items are left processed (counted as
+	// succeeded) if the rollback fails.
+	Rollback map[string]error
+
+	first string
+}
+
+// append failure of the item to the batch
+func (e *BatchError) fail(path string, err error) {
+	if e.Failed == nil {
+		e.Failed = make(map[string]error)
+	}
+
+	if len(e.first) == 0 {
+		e.first = path
+	}
+	e.Failed[path] = err
+}
+
+// append rollback failure of the processed item to the batch
+func (e *BatchError) rollback(path string, err error) {
+	if e.Rollback == nil {
+		e.Rollback = make(map[string]error)
+	}
+	e.Rollback[path] = err
+}
+
+// returns nil if batch has no failures
+func (e *BatchError) orNil() error {
+	if len(e.Failed) == 0 && len(e.Rollback) == 0 {
+		return nil
+	}
+	return e
+}
+
+// paths of failed items in lexicographical order
+func (e *BatchError) paths() []string { return sortedKeys(e.Failed) }
+
+func sortedKeys(m map[string]error) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func (e *BatchError) Error() string {
+	keys := e.paths()
+	seq := make([]string, len(keys))
+	for i, key := range keys {
+		seq[i] = fmt.Sprintf("%s: %s", key, e.Failed[key])
+	}
+
+	for _, key := range sortedKeys(e.Rollback) {
+		seq = append(seq, fmt.Sprintf("rollback %s: %s", key, e.Rollback[key]))
+	}
+
+	return fmt.Sprintf("batch failed %d of %d items: %s",
+		len(e.Failed), len(e.Failed)+e.Succeeded, strings.Join(seq, "; "))
+}
+
+// Unwrap returns failures of the batch, the first failure goes first,
+// others follow in lexicographical order of paths, rollback failures go
+// last. The first failure is the smallest path if the error is constructed
+// by the application.
+func (e *BatchError) Unwrap() []error {
+	keys := e.paths()
+	if _, has := e.Failed[e.first]; has {
+		i := slices.Index(keys, e.first)
+		keys = append(append([]string{e.first}, keys[:i]...), keys[i+1:]...)
+	}
+
+	seq := make([]error, len(keys))
+	for i, key := range keys {
+		seq[i] = e.Failed[key]
+	}
+
+	for _, key := range sortedKeys(e.Rollback) {
+		seq = append(seq, e.Rollback[key])
+	}
+
+	return seq
+}
@@ -0,0 +1,12 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+// Uploader of the file system, as it is built from options
+func (fsys *FileSystem[T]) Uploader() S3Upload { return fsys.upload }
@@ -9,14 +9,31 @@
 package stream
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"io/fs"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
 //------------------------------------------------------------------------------
@@ -46,26 +63,32 @@ func (f info[T]) Info() (fs.FileInfo, error) { return f, nil }
 
 func (f info[T]) s3Key() *string { return s3Key(f.path) }
 
-func s3Key(path string) *string {
-	if path[0] == '/' {
-		return aws.String(path[1:])
-	}
-
-	return aws.String(path)
-}
+func s3Key(path string) *string { return aws.String(PathToKey(path)) }
 
 //------------------------------------------------------------------------------
 
 // reader file descriptor
 type reader[T any] struct {
 	info[T]
-	fs  *FileSystem[T]
-	r   io.ReadCloser
-	can context.CancelFunc
+	fs      *FileSystem[T]
+	ctx     context.Context
+	raw     bool // stored bytes, without decompression
+	rawKey  bool // path is S3 key as-is, without validation (OpenKey)
+	r       io.ReadCloser
+	can     context.CancelFunc
+	closed  bool
+	offset  int64
+	ifRange string
+
+	known       fs.FileInfo
+	ifNoneMatch string
 }
 
 var (
-	_ fs.File = (*reader[any])(nil)
+	_ fs.File     = (*reader[any])(nil)
+	_ io.Seeker   = (*reader[any])(nil)
+	_ io.ReaderAt = (*reader[any])(nil)
+	_ Reopener    = (*reader[any])(nil)
 )
 
 // open read only descriptor to file
@@ -74,51 +97,106 @@ func newReader[T any](fsys *FileSystem[T], name string) *reader[T] {
 		info: info[T]{
 			path: name,
 		},
-		fs: fsys,
+		fs:  fsys,
+		ctx: fsys.ctx,
 	}
 }
 
-// check file's metadata
+// context of I/O, it is cancelled by either the context of file (OpenCtx)
+// or the context of file system
+func (fd *reader[T]) context() (context.Context, context.CancelFunc) {
+	return fd.fs.timeoutContext(fd.ctx)
+}
+
+// check file's metadata, the metadata is obtained with HeadObject before
+// the first read, the first read obtains it from GetObject.
 func (fd *reader[T]) Stat() (fs.FileInfo, error) {
-	if fd.r == nil {
-		if err := fd.lazyOpen(); err != nil {
+	if fd.r == nil && fd.info.attr == nil {
+		ctx, cancel := fd.context()
+		defer cancel()
+
+		stat, err := fd.stat(ctx)
+		if err != nil {
 			return nil, err
 		}
+
+		if info, ok := stat.(info[T]); ok {
+			fd.info.size = info.size
+			fd.info.time = info.time
+			fd.info.attr = info.attr
+		}
 	}
 
-	// if fd.size == 0 {
-	// 	stat, err := fd.fs.Stat(fd.path)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// 	info, ok := stat.(info[T])
-	// 	if !ok {
-	// 		return nil, fs.ErrInvalid
-	// 	}
+	return fd.info, nil
+}
 
-	// 	fd.info.size = info.size
-	// 	fd.info.time = info.time
-	// 	fd.info.attr = info.attr
-	// }
+// obtains metadata with HeadObject, raw keys bypass path validation
+func (fd *reader[T]) stat(ctx context.Context) (fs.FileInfo, error) {
+	if fd.rawKey {
+		return fd.fs.statFile(ctx, fd.path)
+	}
 
-	return fd.info, nil
+	return fd.fs.StatCtx(ctx, fd.path)
 }
 
 func (fd *reader[T]) lazyOpen() error {
 	req := &s3.GetObjectInput{
-		Bucket: aws.String(fd.fs.bucket),
-		Key:    fd.s3Key(),
+		Bucket:              aws.String(fd.fs.bucket),
+		ExpectedBucketOwner: fd.fs.expectedBucketOwner(),
+		Key:                 fd.s3Key(),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), fd.fs.timeout)
+	if fd.offset > 0 || fd.ifRange != "" {
+		req.Range = aws.String(fmt.Sprintf("bytes=%d-", fd.offset))
+		if fd.ifRange != "" {
+			req.IfMatch = aws.String(fd.ifRange)
+		}
+	}
+
+	if fd.ifNoneMatch != "" {
+		req.IfNoneMatch = aws.String(fd.ifNoneMatch)
+	}
 
-	val, err := fd.fs.api.GetObject(ctx, req)
+	// the first part of parallel download probes the size of object,
+	// objects smaller than part size are served by it
+	probe := fd.fs.partSize > 0 && req.Range == nil && req.IfNoneMatch == nil
+	if probe {
+		req.Range = aws.String(fmt.Sprintf("bytes=0-%d", fd.fs.partSize-1))
+	}
+
+	if fd.fs.checksum && req.Range == nil {
+		req.ChecksumMode = types.ChecksumModeEnabled
+	}
+
+	ctx, cancel := fd.context()
+
+	fd.fs.debug("GetObject", req.Key)
+	val, err := fd.fs.api.GetObject(ctx, req, fd.fs.regionOptions(req.Key)...)
 	if err != nil {
 		cancel()
 
 		switch {
-		case recoverNoSuchKey(err):
+		case fd.fs.isNotFound(err):
 			return fs.ErrNotExist
+		case fd.ifNoneMatch != "" && recoverNotModified(err):
+			fd.notModified()
+			return nil
+		case req.Range != nil && fd.ifRange == "" && recoverInvalidRange(err):
+			// the position is beyond the end of object (e.g. Seek)
+			fd.r = io.NopCloser(strings.NewReader(""))
+			return nil
+		case fd.ifRange != "" && recoverPreconditionFailed(err):
+			return &fs.PathError{
+				Op:   "open",
+				Path: fd.path,
+				Err:  fmt.Errorf("%w: %w", ErrRangeIgnored, err),
+			}
+		case recoverAccessDenied(err):
+			return &fs.PathError{
+				Op:   "open",
+				Path: fd.path,
+				Err:  fmt.Errorf("%w: %w", ErrAccessDenied, err),
+			}
 		default:
 			return &fs.PathError{
 				Op:   "open",
@@ -128,14 +206,67 @@ func (fd *reader[T]) lazyOpen() error {
 		}
 	}
 
+	size := aws.ToInt64(val.ContentLength)
+	if fd.fs.maxSize > 0 && size > fd.fs.maxSize {
+		val.Body.Close()
+		cancel()
+		return &fs.PathError{
+			Op:   "open",
+			Path: fd.path,
+			Err:  fmt.Errorf("%w: %d bytes exceeds %d", ErrObjectTooLarge, size, fd.fs.maxSize),
+		}
+	}
+
+	if req.Range != nil {
+		// S3 responds 200 with entire content if the range is not satisfied
+		if val.ContentRange == nil && !probe {
+			val.Body.Close()
+			cancel()
+			return &fs.PathError{
+				Op:   "open",
+				Path: fd.path,
+				Err:  ErrRangeIgnored,
+			}
+		}
+		size = contentRangeSize(aws.ToString(val.ContentRange), size)
+	}
+
+	if probe && size > fd.fs.partSize {
+		if val.Body, err = fd.lazyDownload(ctx, req, val, size); err != nil {
+			cancel()
+			return err
+		}
+	}
+
 	fd.r = val.Body
+	if req.Range == nil || probe {
+		if fd.fs.checksum {
+			fd.r = fd.verify(val)
+		}
+
+		if fd.r, err = fd.decompress(fd.r); err != nil {
+			val.Body.Close()
+			cancel()
+			return &fs.PathError{
+				Op:   "open",
+				Path: fd.path,
+				Err:  err,
+			}
+		}
+	}
+
 	fd.can = cancel
-	fd.info.size = aws.ToInt64(val.ContentLength)
+	fd.info.size = size
 	fd.info.time = aws.ToTime(val.LastModified)
 	fd.info.attr = new(T)
 
 	fd.fs.codec.DecodeGetOutput(val, fd.info.attr)
-	if fd.fs.signer != nil && fd.fs.codec.s != nil {
+	if err := fd.fs.decodeTagging(ctx, "open", fd.path, fd.info.attr); err != nil {
+		fd.release()
+		return err
+	}
+
+	if fd.fs.signer != nil && fd.fs.autoPreSign && fd.fs.codec.s != nil {
 		if url, err := fd.fs.preSignGetUrl(fd.s3Key()); err == nil {
 			fd.fs.codec.s.Put(fd.info.attr, url)
 		}
@@ -144,17 +275,404 @@ func (fd *reader[T]) lazyOpen() error {
 	return nil
 }
 
+// downloads objects larger than part size with parallel ranged requests
+// into temporary file, the probe response serves the first part.
+func (fd *reader[T]) lazyDownload(ctx context.Context, probe *s3.GetObjectInput, val *s3.GetObjectOutput, size int64) (io.ReadCloser, error) {
+	if fd.fs.maxSize > 0 && size > fd.fs.maxSize {
+		val.Body.Close()
+		return nil, &fs.PathError{
+			Op:   "open",
+			Path: fd.path,
+			Err:  fmt.Errorf("%w: %d bytes exceeds %d", ErrObjectTooLarge, size, fd.fs.maxSize),
+		}
+	}
+
+	req := *probe
+	req.Range = nil
+	// all parts must belong to the same version of the object
+	if val.ETag != nil {
+		req.IfMatch = val.ETag
+	}
+
+	file, err := os.CreateTemp("", "stream-*")
+	if err != nil {
+		val.Body.Close()
+		return nil, &fs.PathError{Op: "open", Path: fd.path, Err: err}
+	}
+	tmp := tempFile{File: file}
+
+	api := &probedClient{
+		DownloadAPIClient: fd.fs.api,
+		rng:               aws.ToString(probe.Range),
+		val:               val,
+	}
+	defer api.release()
+
+	downloader := manager.NewDownloader(api, func(d *manager.Downloader) {
+		d.PartSize = fd.fs.partSize
+		d.Concurrency = fd.fs.concurrency
+	})
+
+	fd.fs.debug("GetObject", req.Key, "parallel", fd.fs.concurrency)
+	_, err = downloader.Download(ctx, tmp, &req, manager.WithDownloaderClientOptions(fd.fs.regionOptions(req.Key)...))
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		return nil, &fs.PathError{Op: "open", Path: fd.path, Err: err}
+	}
+
+	return tmp, nil
+}
+
+// serves the first part of parallel download with the probe response,
+// other parts are requested from S3
+type probedClient struct {
+	manager.DownloadAPIClient
+	sync.Mutex
+	rng string
+	val *s3.GetObjectOutput
+}
+
+func (c *probedClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.Lock()
+	if val := c.val; val != nil && aws.ToString(params.Range) == c.rng {
+		c.val = nil
+		c.Unlock()
+		return val, nil
+	}
+	c.Unlock()
+
+	return c.DownloadAPIClient.GetObject(ctx, params, optFns...)
+}
+
+// closes the probe response if download has not consumed it
+func (c *probedClient) release() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.val != nil {
+		c.val.Body.Close()
+		c.val = nil
+	}
+}
+
+// temporary file, removed on close
+type tempFile struct{ *os.File }
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.File.Name())
+	return err
+}
+
+// wraps the body with decompressor of the longest matching path suffix
+func (fd *reader[T]) decompress(body io.ReadCloser) (io.ReadCloser, error) {
+	if fd.raw {
+		return body, nil
+	}
+
+	var (
+		suffix string
+		decode func(io.Reader) (io.Reader, error)
+	)
+	for sfx, f := range fd.fs.decompress {
+		if len(sfx) > len(suffix) && strings.HasSuffix(fd.path, sfx) {
+			suffix, decode = sfx, f
+		}
+	}
+
+	if decode == nil {
+		return body, nil
+	}
+
+	r, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressor{Reader: r, body: body}, nil
+}
+
+// closes the decompressor (if it is io.Closer) and the body
+type decompressor struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (d decompressor) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			d.body.Close()
+			return err
+		}
+	}
+
+	return d.body.Close()
+}
+
+// wraps the body with running checksum of the strongest algorithm reported
+// by S3, the body is returned as-is if there is nothing to verify.
+func (fd *reader[T]) verify(val *s3.GetObjectOutput) io.ReadCloser {
+	var (
+		expect string
+		algo   hash.Hash
+	)
+	switch {
+	case val.ChecksumSHA256 != nil:
+		expect, algo = aws.ToString(val.ChecksumSHA256), sha256.New()
+	case val.ChecksumSHA1 != nil:
+		expect, algo = aws.ToString(val.ChecksumSHA1), sha1.New()
+	case val.ChecksumCRC32C != nil:
+		expect, algo = aws.ToString(val.ChecksumCRC32C), crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case val.ChecksumCRC32 != nil:
+		expect, algo = aws.ToString(val.ChecksumCRC32), crc32.NewIEEE()
+	}
+
+	// checksum of multipart object is the checksum of parts checksums ("...-N")
+	if algo == nil || strings.Contains(expect, "-") {
+		return val.Body
+	}
+
+	return &checksum{body: val.Body, path: fd.path, hash: algo, expect: expect}
+}
+
+// computes the checksum of body, it is verified on close if body is read to the end
+type checksum struct {
+	body   io.ReadCloser
+	path   string
+	hash   hash.Hash
+	expect string
+	eof    bool
+}
+
+func (c *checksum) Read(b []byte) (int, error) {
+	n, err := c.body.Read(b)
+	c.hash.Write(b[:n])
+	if err == io.EOF {
+		c.eof = true
+	}
+
+	return n, err
+}
+
+func (c *checksum) Close() error {
+	if err := c.body.Close(); err != nil {
+		return err
+	}
+
+	if !c.eof {
+		return nil
+	}
+
+	if sum := base64.StdEncoding.EncodeToString(c.hash.Sum(nil)); sum != c.expect {
+		return &fs.PathError{
+			Op:   "read",
+			Path: c.path,
+			Err:  fmt.Errorf("%w: %s, expected %s", ErrChecksumMismatch, sum, c.expect),
+		}
+	}
+
+	return nil
+}
+
+// reuses known metadata, the body is not transferred for unchanged object
+func (fd *reader[T]) notModified() {
+	fd.info.size = fd.known.Size()
+	fd.info.time = fd.known.ModTime()
+	if known, ok := fd.known.(info[T]); ok {
+		fd.info.attr = known.attr
+	}
+
+	fd.r = io.NopCloser(failingReader{
+		err: &fs.PathError{Op: "read", Path: fd.path, Err: ErrNotModified},
+	})
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+// parses the total size of the object from "bytes 100-199/200"
+func contentRangeSize(contentRange string, fallback int64) int64 {
+	i := strings.LastIndexByte(contentRange, '/')
+	if i == -1 {
+		return fallback
+	}
+
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return size
+}
+
 func (fd *reader[T]) Read(b []byte) (int, error) {
+	if fd.closed {
+		return 0, fs.ErrClosed
+	}
+
 	if fd.r == nil {
 		if err := fd.lazyOpen(); err != nil {
 			return 0, err
 		}
 	}
 
-	return fd.r.Read(b)
+	n, err := fd.r.Read(b)
+	fd.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the position of the next Read. The body of the object is
+// re-opened from the new position using S3 range request, io.SeekEnd requires
+// the size of object, which is obtained with HeadObject before the first read.
+// Seek addresses bytes of the stored object, decompression (see
+// WithDecompressBySuffix) is only applied to reads from the beginning.
+func (fd *reader[T]) Seek(offset int64, whence int) (int64, error) {
+	if fd.closed {
+		return 0, fs.ErrClosed
+	}
+
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = fd.offset + offset
+	case io.SeekEnd:
+		fi, err := fd.Stat()
+		if err != nil {
+			return 0, err
+		}
+		pos = fi.Size() + offset
+	default:
+		return 0, &fs.PathError{
+			Op:   "seek",
+			Path: fd.path,
+			Err:  fmt.Errorf("%w: whence %d", fs.ErrInvalid, whence),
+		}
+	}
+
+	if pos < 0 {
+		return 0, &fs.PathError{
+			Op:   "seek",
+			Path: fd.path,
+			Err:  fmt.Errorf("%w: negative position %d", fs.ErrInvalid, pos),
+		}
+	}
+
+	if pos == fd.offset {
+		return pos, nil
+	}
+
+	fd.release()
+
+	fd.offset = pos
+	return pos, nil
+}
+
+// releases the body of object, the next read opens it again
+func (fd *reader[T]) release() {
+	if fd.r == nil {
+		return
+	}
+
+	if fd.can != nil {
+		fd.can()
+	}
+	fd.r.Close()
+	fd.r, fd.can = nil, nil
+}
+
+// Reopen rewinds the file to the beginning, the current body is closed and
+// the object is requested again (e.g. sniff the content then process it).
+func (fd *reader[T]) Reopen() error {
+	if fd.closed {
+		return fs.ErrClosed
+	}
+
+	fd.release()
+
+	fd.offset = 0
+	return fd.lazyOpen()
+}
+
+// ReadAt reads len(b) bytes from the offset using bounded range request,
+// the position of sequential Read is not affected. It returns io.EOF if
+// fewer bytes are read because the end of object is reached.
+func (fd *reader[T]) ReadAt(b []byte, off int64) (int, error) {
+	if fd.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if off < 0 {
+		return 0, &fs.PathError{
+			Op:   "read",
+			Path: fd.path,
+			Err:  fmt.Errorf("%w: negative offset %d", fs.ErrInvalid, off),
+		}
+	}
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	req := &s3.GetObjectInput{
+		Bucket:              aws.String(fd.fs.bucket),
+		ExpectedBucketOwner: fd.fs.expectedBucketOwner(),
+		Key:                 fd.s3Key(),
+		Range:               aws.String(fmt.Sprintf("bytes=%d-%d", off, off+int64(len(b))-1)),
+	}
+
+	ctx, cancel := fd.context()
+	defer cancel()
+
+	fd.fs.debug("GetObject", req.Key)
+	val, err := fd.fs.api.GetObject(ctx, req, fd.fs.regionOptions(req.Key)...)
+	switch {
+	case err == nil:
+	case recoverInvalidRange(err):
+		return 0, io.EOF
+	case fd.fs.isNotFound(err):
+		return 0, fs.ErrNotExist
+	default:
+		return 0, &fs.PathError{
+			Op:   "read",
+			Path: fd.path,
+			Err:  err,
+		}
+	}
+	defer val.Body.Close()
+
+	// S3 responds 200 with entire content if the range is not satisfied
+	if val.ContentRange == nil {
+		return 0, &fs.PathError{
+			Op:   "read",
+			Path: fd.path,
+			Err:  ErrRangeIgnored,
+		}
+	}
+
+	n, err := io.ReadFull(val.Body, b)
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		return n, io.EOF
+	case err != nil:
+		return n, &fs.PathError{
+			Op:   "read",
+			Path: fd.path,
+			Err:  err,
+		}
+	}
+
+	return n, nil
 }
 
 func (fd *reader[T]) Close() error {
+	fd.closed = true
+
 	if fd.r == nil {
 		return nil
 	}
@@ -180,11 +698,18 @@ func (fd *reader[T]) Close() error {
 type writer[T any] struct {
 	info[T]
 	fs     *FileSystem[T]
+	ctx    context.Context
 	w      *io.PipeWriter
 	r      *io.PipeReader
 	wg     sync.WaitGroup
 	cancel context.CancelFunc
 	err    error
+	closed bool
+	buf    *bytes.Buffer
+	hash   hash.Hash
+	digest []byte
+
+	exclusive bool
 }
 
 var (
@@ -193,13 +718,94 @@ var (
 )
 
 func newWriter[T any](fsys *FileSystem[T], path string, attr *T) *writer[T] {
-	return &writer[T]{
+	fd := &writer[T]{
 		info: info[T]{
 			path: path,
 			attr: attr,
 		},
-		fs: fsys,
+		fs:  fsys,
+		ctx: fsys.ctx,
 	}
+
+	if fsys.smallWrites > 0 {
+		fd.buf = &bytes.Buffer{}
+	}
+
+	return fd
+}
+
+func (fd *writer[T]) putObjectInput(body io.Reader) *s3.PutObjectInput {
+	req := &s3.PutObjectInput{
+		Bucket:              aws.String(fd.fs.bucket),
+		ExpectedBucketOwner: fd.fs.expectedBucketOwner(),
+		Key:                 fd.s3Key(),
+		Body:                body,
+		Metadata:            make(map[string]string),
+	}
+	fd.fs.encodeMetadata(fd.ctx, fd.attr, req)
+
+	if fd.fs.sse != "" {
+		req.ServerSideEncryption = fd.fs.sse
+	}
+
+	if fd.fs.sseKMSKeyID != "" {
+		req.SSEKMSKeyId = aws.String(fd.fs.sseKMSKeyID)
+	}
+
+	if fd.fs.putHook != nil {
+		fd.fs.putHook(req)
+	}
+
+	return req
+}
+
+// options of write requests, exclusive writes are conditional on absence
+func (fd *writer[T]) requestOptions(key *string) []func(*s3.Options) {
+	opts := fd.fs.regionOptions(key)
+	if fd.exclusive {
+		opts = append(opts, withIfNoneMatch)
+	}
+
+	return opts
+}
+
+func (fd *writer[T]) writeError(err error) error {
+	if fd.exclusive && recoverPreconditionFailed(err) {
+		err = fmt.Errorf("%w: %w", fs.ErrExist, err)
+	}
+
+	return &fs.PathError{
+		Op:   "write",
+		Path: fd.path,
+		Err:  err,
+	}
+}
+
+// The SDK does not model If-None-Match of PutObject, the header is set on
+// requests completing the write: PutObject or CompleteMultipartUpload.
+func withIfNoneMatch(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Build.Add(
+			middleware.BuildMiddlewareFunc("IfNoneMatch",
+				func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+					switch awsmiddleware.GetOperationName(ctx) {
+					case "PutObject", "CompleteMultipartUpload":
+						if req, ok := in.Request.(*smithyhttp.Request); ok {
+							req.Header.Set("If-None-Match", "*")
+						}
+					}
+					return next.HandleBuild(ctx, in)
+				},
+			),
+			middleware.After,
+		)
+	})
+}
+
+// context of I/O, it is cancelled by either the context of file (CreateCtx)
+// or the context of file system
+func (fd *writer[T]) context() (context.Context, context.CancelFunc) {
+	return fd.fs.timeoutContext(fd.ctx)
 }
 
 func (fd *writer[T]) lazyOpen() {
@@ -207,44 +813,72 @@ func (fd *writer[T]) lazyOpen() {
 	fd.wg = sync.WaitGroup{}
 	fd.wg.Add(1)
 
+	ctx, cancel := fd.context()
+	fd.cancel = cancel
+
+	// cancellation unblocks the upload waiting for the data
+	stop := context.AfterFunc(ctx, func() {
+		fd.r.CloseWithError(&fs.PathError{Op: "write", Path: fd.path, Err: ctx.Err()})
+	})
+
 	go func() {
 		defer fd.wg.Done()
-
-		ctx, cancel := context.WithTimeout(context.Background(), fd.fs.timeout)
 		defer cancel()
+		defer stop()
 
-		req := &s3.PutObjectInput{
-			Bucket:   aws.String(fd.fs.bucket),
-			Key:      fd.s3Key(),
-			Body:     fd.r,
-			Metadata: make(map[string]string),
-		}
-		fd.fs.codec.EncodePutInput(fd.attr, req)
+		req := fd.putObjectInput(fd.r)
 
-		fd.cancel = cancel
-		if _, err := fd.fs.upload.Upload(ctx, req); err != nil {
-			fd.err = &fs.PathError{
-				Op:   "write",
-				Path: fd.path,
-				Err:  err,
+		// parts are aborted by the writer, the uploader fails to abort them
+		// with the cancelled context
+		leaveParts := func(u *manager.Uploader) { u.LeavePartsOnError = true }
+
+		fd.fs.debug("PutObject", req.Key)
+		_, err := fd.fs.upload.Upload(ctx, req, manager.WithUploaderRequestOptions(fd.requestOptions(req.Key)...), leaveParts)
+		if err != nil {
+			var upload manager.MultiUploadFailure
+			if errors.As(err, &upload) {
+				fd.abort(upload.UploadID())
+			}
+
+			if ctx.Err() != nil {
+				fd.err = &fs.PathError{Op: "write", Path: fd.path, Err: ctx.Err()}
+			} else {
+				fd.err = fd.writeError(err)
 			}
+
+			// the error is reported to blocked and subsequent writes
+			fd.r.CloseWithError(fd.err)
+			return
 		}
 		fd.r.Close()
 	}()
 }
 
-func (fd *writer[T]) preSignPutUrl() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), fd.fs.timeout)
+// aborts multipart upload, it is not bound to the cancellation of file
+func (fd *writer[T]) abort(uploadID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(fd.ctx), fd.fs.timeout)
 	defer cancel()
 
-	req := &s3.PutObjectInput{
-		Bucket:   aws.String(fd.fs.bucket),
-		Key:      fd.s3Key(),
-		Metadata: make(map[string]string),
+	req := &s3.AbortMultipartUploadInput{
+		Bucket:              aws.String(fd.fs.bucket),
+		ExpectedBucketOwner: fd.fs.expectedBucketOwner(),
+		Key:                 fd.s3Key(),
+		UploadId:            aws.String(uploadID),
 	}
-	fd.fs.codec.EncodePutInput(fd.attr, req)
 
-	val, err := fd.fs.signer.PresignPutObject(ctx, req, s3.WithPresignExpires(fd.fs.ttlSignedUrl))
+	fd.fs.debug("AbortMultipartUpload", req.Key)
+	if _, err := fd.fs.api.AbortMultipartUpload(ctx, req, fd.fs.regionOptions(req.Key)...); err != nil {
+		fd.fs.debug("AbortMultipartUpload", req.Key, "error", err)
+	}
+}
+
+func (fd *writer[T]) preSignPutUrl() (string, error) {
+	ctx, cancel := fd.context()
+	defer cancel()
+
+	req := fd.putObjectInput(nil)
+
+	val, err := fd.fs.signer.PresignPutObject(ctx, req, s3.WithPresignExpires(fd.fs.ttlSignedUrl), fd.fs.presignRegionOptions(req.Key))
 	if err != nil {
 		return "", &fs.PathError{
 			Op:   "presign",
@@ -256,27 +890,103 @@ func (fd *writer[T]) preSignPutUrl() (string, error) {
 	return val.URL, nil
 }
 
+// writes small object with single PutObject call
+func (fd *writer[T]) putObject() error {
+	ctx, cancel := fd.context()
+	defer cancel()
+
+	req := fd.putObjectInput(bytes.NewReader(fd.buf.Bytes()))
+	req.ContentLength = aws.Int64(int64(fd.buf.Len()))
+
+	// S3 rejects the object if it is corrupted in transit
+	md5sum := md5.Sum(fd.buf.Bytes())
+	req.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(md5sum[:]))
+
+	fd.fs.debug("PutObject", req.Key)
+	if _, err := fd.fs.api.PutObject(ctx, req, fd.requestOptions(req.Key)...); err != nil {
+		return fd.writeError(err)
+	}
+
+	return nil
+}
+
+// switches buffered writer to streaming
+func (fd *writer[T]) spill() error {
+	buf := fd.buf.Bytes()
+	fd.buf = nil
+	fd.lazyOpen()
+
+	if _, err := fd.w.Write(buf); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (fd *writer[T]) Write(p []byte) (int, error) {
+	n, err := fd.write(p)
+	if fd.hash != nil {
+		fd.hash.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func (fd *writer[T]) write(p []byte) (int, error) {
+	if fd.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if fd.buf != nil {
+		if int64(fd.buf.Len()+len(p)) <= fd.fs.smallWrites {
+			return fd.buf.Write(p)
+		}
+
+		if err := fd.spill(); err != nil {
+			return 0, err
+		}
+	}
+
 	if fd.r == nil && fd.w == nil {
 		fd.lazyOpen()
 	}
 
-	if fd.err != nil {
-		return 0, fd.err
+	// Note: IO fails with the error of upload if pipe is closed.
+	return fd.w.Write(p)
+}
+
+func (fd *writer[T]) Close() error {
+	if fd.closed {
+		return nil
+	}
+	fd.closed = true
+
+	// nothing is uploaded if the file is closed without writes
+	uploaded := (fd.buf != nil && fd.buf.Len() > 0) || (fd.w != nil && fd.r != nil)
+
+	if err := fd.close(); err != nil {
+		return err
 	}
 
-	// Note: IO fails if pipe is closed.
-	n, err := fd.w.Write(p)
-	if fd.err != nil {
-		return 0, fd.err
+	if fd.fs.dirMarkers && uploaded {
+		ctx, cancel := fd.context()
+		defer cancel()
+
+		if err := fd.fs.createMarkers(ctx, fd.path); err != nil {
+			return err
+		}
 	}
 
-	return n, err
+	if fd.hash != nil {
+		fd.digest = fd.hash.Sum(nil)
+	}
+
+	return nil
 }
 
-func (fd *writer[T]) Close() error {
-	if fd.err != nil {
-		return fd.err
+func (fd *writer[T]) close() error {
+	if fd.buf != nil && fd.buf.Len() > 0 {
+		return fd.putObject()
 	}
 
 	if fd.w != nil && fd.r != nil {
@@ -307,8 +1017,20 @@ func (fd *writer[T]) Stat() (fs.FileInfo, error) {
 	return fd.info, nil
 }
 
-// Cancel effect of file i/o
+// Cancel effect of file i/o, the file is closed afterwards.
+// Cancel of closed file is no-op.
 func (fd *writer[T]) Cancel() error {
-	fd.cancel()
+	if fd.closed {
+		return nil
+	}
+	fd.closed = true
+	fd.buf = nil
+
+	if fd.cancel != nil {
+		fd.cancel()
+		fd.w.CloseWithError(context.Canceled)
+		fd.wg.Wait()
+	}
+
 	return nil
 }
@@ -9,9 +9,54 @@
 package stream
 
 import (
+	"fmt"
 	"io/fs"
+	"strings"
+	"unicode/utf8"
 )
 
+// Normalize path into canonical form, it collapses repeated slashes and
+// ensures the leading "/". The trailing "/" of directories is preserved.
+// For example, "tmpDir//test.txt" becomes "/tmpDir/test.txt".
+func NormalizePath(path string) string {
+	if len(path) == 0 {
+		return path
+	}
+
+	seq := strings.Builder{}
+	seq.Grow(len(path) + 1)
+	seq.WriteByte('/')
+
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && (i == 0 || path[i-1] == '/') {
+			continue
+		}
+		seq.WriteByte(path[i])
+	}
+
+	return seq.String()
+}
+
+// PathToKey maps the absolute path of the file system to S3 object key,
+// stripping the leading "/". For example, "/a/b" becomes "a/b".
+func PathToKey(path string) string {
+	if len(path) > 0 && path[0] == '/' {
+		return path[1:]
+	}
+
+	return path
+}
+
+// KeyToPath maps S3 object key to the absolute path of the file system,
+// it is the inverse of PathToKey. For example, "a/b" becomes "/a/b".
+func KeyToPath(key string) string {
+	if len(key) > 0 && key[0] == '/' {
+		return key
+	}
+
+	return "/" + key
+}
+
 // The file system requires absolute path starting from "/"
 // The file should not end with "/"
 func IsValidFile(path string) bool {
@@ -24,10 +69,15 @@ func RequireValidFile(ctx, path string) error {
 		return nil
 	}
 
+	err := explainInvalidPath(path)
+	if err == nil && path[len(path)-1] == '/' {
+		err = fmt.Errorf("%w: file must not end with '/'", fs.ErrInvalid)
+	}
+
 	return &fs.PathError{
 		Op:   ctx,
 		Path: path,
-		Err:  fs.ErrInvalid,
+		Err:  orInvalid(err),
 	}
 }
 
@@ -57,7 +107,7 @@ func RequireValidPath(ctx, path string) error {
 	return &fs.PathError{
 		Op:   ctx,
 		Path: path,
-		Err:  fs.ErrInvalid,
+		Err:  orInvalid(explainInvalidPath(path)),
 	}
 }
 
@@ -80,9 +130,51 @@ func RequireValidDir(ctx, path string) error {
 		return nil
 	}
 
+	err := explainInvalidPath(path)
+	if err == nil && path[len(path)-1] != '/' {
+		err = fmt.Errorf("%w: dir must end with '/'", fs.ErrInvalid)
+	}
+
 	return &fs.PathError{
 		Op:   ctx,
 		Path: path,
-		Err:  fs.ErrInvalid,
+		Err:  orInvalid(err),
+	}
+}
+
+// explains the problem with path, a single trailing "/" is not considered.
+// It returns nil if no specific problem is found.
+func explainInvalidPath(path string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("%w: empty path", fs.ErrInvalid)
+	}
+
+	if path[0] != '/' {
+		return fmt.Errorf("%w: path must start with '/'", fs.ErrInvalid)
+	}
+
+	if !utf8.ValidString(path) {
+		return fmt.Errorf("%w: path is not valid UTF-8", fs.ErrInvalid)
+	}
+
+	seq := strings.Split(path[1:], "/")
+	for i, x := range seq {
+		switch x {
+		case "":
+			if i != len(seq)-1 {
+				return fmt.Errorf("%w: empty path component (double slash)", fs.ErrInvalid)
+			}
+		case ".", "..":
+			return fmt.Errorf("%w: path component '%s' is not allowed", fs.ErrInvalid, x)
+		}
+	}
+
+	return nil
+}
+
+func orInvalid(err error) error {
+	if err == nil {
+		return fs.ErrInvalid
 	}
+	return err
 }
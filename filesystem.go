@@ -14,16 +14,24 @@
 package stream
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"iter"
+	"path"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/fogfish/opts"
 )
 
@@ -32,6 +40,8 @@ type FileSystem[T any] struct {
 	Opts
 	bucket string
 	codec  *codec[T]
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 var (
@@ -39,14 +49,24 @@ var (
 	_ fs.StatFS          = (*FileSystem[struct{}])(nil)
 	_ fs.ReadDirFS       = (*FileSystem[struct{}])(nil)
 	_ fs.GlobFS          = (*FileSystem[struct{}])(nil)
+	_ fs.ReadFileFS      = (*FileSystem[struct{}])(nil)
 	_ CreateFS[struct{}] = (*FileSystem[struct{}])(nil)
 	_ RemoveFS           = (*FileSystem[struct{}])(nil)
 	_ CopyFS             = (*FileSystem[struct{}])(nil)
+	_ AtomicFS           = (*FileSystem[struct{}])(nil)
+	_ RenameFS           = (*FileSystem[struct{}])(nil)
 )
 
 // Create a file system instance, mounting S3 Bucket. Use Option type to
 // configure file system.
 func New[T any](bucket string, opt ...Option) (*FileSystem[T], error) {
+	return NewWithContext[T](context.Background(), bucket, opt...)
+}
+
+// Create a file system instance bound to the context, all I/O operations
+// derive their context from it. Cancelling the context (or Close of
+// the file system) aborts every in-flight stream (e.g. graceful shutdown).
+func NewWithContext[T any](ctx context.Context, bucket string, opt ...Option) (*FileSystem[T], error) {
 	if len(bucket) == 0 {
 		return nil, fmt.Errorf("bucket is not defined")
 	}
@@ -54,28 +74,59 @@ func New[T any](bucket string, opt ...Option) (*FileSystem[T], error) {
 	fsys := FileSystem[T]{
 		Opts:   optsDefault(),
 		bucket: bucket,
-		codec:  newCodec[T](),
 	}
 
 	if err := opts.Apply(&fsys.Opts, opt); err != nil {
 		return nil, err
 	}
 
-	if fsys.api == nil {
+	if err := fsys.checkUpload(); err != nil {
+		return nil, err
+	}
+
+	if err := fsys.checkDownload(); err != nil {
+		return nil, err
+	}
+
+	fsys.codec = newCodec[T](fsys.metaDelim)
+
+	if fsys.api == nil && fsys.config == nil {
 		if err := optsDefaultS3(&fsys.Opts); err != nil {
 			return nil, err
 		}
 	}
 
+	if err := optsClients(&fsys.Opts); err != nil {
+		return nil, err
+	}
+	optsMetrics(&fsys.Opts)
+
+	fsys.ctx, fsys.cancel = context.WithCancel(ctx)
+
 	return &fsys, fsys.checkRequired()
 }
 
+// Create a file system instance from the existing aws.Config, the S3 client,
+// uploader and url signer are built from the config. It is shortcut of
+// New with WithConfig option, convenient for sharing the config across file
+// systems. Clients supplied with options (e.g. WithS3) take precedence.
+func NewFromConfig[T any](bucket string, cfg aws.Config, opt ...Option) (*FileSystem[T], error) {
+	return New[T](bucket, append([]Option{WithConfig(cfg)}, opt...)...)
+}
+
 // Create a file system instance, mounting S3 Bucket. Use Option type to
 // configure file system.
 func NewFS(bucket string, opts ...Option) (*FileSystem[struct{}], error) {
 	return New[struct{}](bucket, opts...)
 }
 
+// Close the file system, aborting all in-flight I/O operations. Any I/O
+// fails with context.Canceled afterwards.
+func (fsys *FileSystem[T]) Close() error {
+	fsys.cancel()
+	return nil
+}
+
 // To open the file for writing use `Create` function giving the absolute path
 // starting with `/`, the returned file descriptor is a composite of
 // `io.Writer`, `io.Closer` and `stream.Stat`. Utilize Golang's convenient
@@ -83,7 +134,11 @@ func NewFS(bucket string, opts ...Option) (*FileSystem[struct{}], error) {
 // it's crucial to close the stream. Failure to do so would cause data loss.
 // The object is considered successfully created on S3 only if all `Write`
 // operations and subsequent `Close` actions are successful.
+//
+// S3 has no directories, creating `/a/b` is legal even if `/a` exists as
+// an object. Local file system fails such operation with ErrNotDir.
 func (fsys *FileSystem[T]) Create(path string, attr *T) (File, error) {
+	path = fsys.canonical(path)
 	if err := RequireValidFile("create", path); err != nil {
 		return nil, err
 	}
@@ -91,11 +146,57 @@ func (fsys *FileSystem[T]) Create(path string, attr *T) (File, error) {
 	return newWriter(fsys, path, attr), nil
 }
 
+// CreateCtx is Create bound to the context. The context cancels the upload
+// and supplies values for WithContextMetadataKey.
+func (fsys *FileSystem[T]) CreateCtx(ctx context.Context, path string, attr *T) (File, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("create", path); err != nil {
+		return nil, err
+	}
+
+	fd := newWriter(fsys, path, attr)
+	fd.ctx = ctx
+
+	return fd, nil
+}
+
+// CreateExclusive is Create failing if the object already exists, it is
+// "create only if absent" for idempotent producers. The write is conditional
+// on If-None-Match: "*", Close fails with fs.ErrExist if the object exists.
+func (fsys *FileSystem[T]) CreateExclusive(path string, attr *T) (File, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("create", path); err != nil {
+		return nil, err
+	}
+
+	fd := newWriter(fsys, path, attr)
+	fd.exclusive = true
+
+	return fd, nil
+}
+
+// CreateHashing is Create computing the digest of written bytes as they
+// stream (e.g. sha256.New), avoiding the second pass over the object.
+// The returned function yields the digest once Close succeeds, it is nil
+// before or if the upload fails.
+func (fsys *FileSystem[T]) CreateHashing(path string, attr *T, algo Hash) (File, func() []byte, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("create", path); err != nil {
+		return nil, nil, err
+	}
+
+	fd := newWriter(fsys, path, attr)
+	fd.hash = algo()
+
+	return fd, func() []byte { return fd.digest }, nil
+}
+
 // To open the file for reading use `Open` function giving the absolute path
 // starting with `/`, the returned file descriptor is a composite of
 // `io.Reader`, `io.Closer` and `stream.Stat`. Utilize Golang's convenient
 // streaming methods to consume S3 object seamlessly.
 func (fsys *FileSystem[T]) Open(path string) (fs.File, error) {
+	path = fsys.canonical(path)
 	if err := RequireValidPath("open", path); err != nil {
 		return nil, err
 	}
@@ -107,33 +208,281 @@ func (fsys *FileSystem[T]) Open(path string) (fs.File, error) {
 	return newReader(fsys, path), nil
 }
 
+// GetObject reads the object with a single GetObject request, returning
+// its info, metadata and the body at once.
+func (fsys *FileSystem[T]) GetObject(path string) (*Object[T], error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("open", path); err != nil {
+		return nil, err
+	}
+
+	fd := newReader(fsys, path)
+	if err := fd.lazyOpen(); err != nil {
+		if err == fs.ErrNotExist {
+			err = &fs.PathError{Op: "open", Path: path, Err: err}
+		}
+		return nil, err
+	}
+
+	return &Object[T]{Info: fd.info, Attr: fd.info.attr, Body: fd}, nil
+}
+
+// OpenCtx is Open bound to the context. The context cancels reading of the
+// file, the I/O timeout of the file system remains the upper bound.
+func (fsys *FileSystem[T]) OpenCtx(ctx context.Context, path string) (fs.File, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidPath("open", path); err != nil {
+		return nil, err
+	}
+
+	if IsValidDir(path) {
+		dd := openDir(fsys, path)
+		dd.ctx = ctx
+		return dd, nil
+	}
+
+	fd := newReader(fsys, path)
+	fd.ctx = ctx
+	return fd, nil
+}
+
+// ReadFile reads the named file and returns its contents. The object is read
+// with single GetObject call, which is sized by the response.
+func (fsys *FileSystem[T]) ReadFile(path string) ([]byte, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("open", path); err != nil {
+		return nil, err
+	}
+
+	fd := newReader(fsys, path)
+	defer fd.Close()
+
+	if err := fd.lazyOpen(); err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, fd.size))
+	if _, err := buf.ReadFrom(fd); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Tail reads the last n bytes of the file (e.g. end of large log) using
+// suffix range request, the whole file is returned if it is shorter than n.
+func (fsys *FileSystem[T]) Tail(path string, n int64) ([]byte, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("tail", path); err != nil {
+		return nil, err
+	}
+
+	if n <= 0 {
+		return nil, &fs.PathError{
+			Op:   "tail",
+			Path: path,
+			Err:  fmt.Errorf("%w: non-positive length %d", fs.ErrInvalid, n),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	req := &s3.GetObjectInput{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3Key(path),
+		Range:               aws.String(fmt.Sprintf("bytes=-%d", n)),
+	}
+
+	fsys.debug("GetObject", req.Key)
+	val, err := fsys.api.GetObject(ctx, req, fsys.regionOptions(req.Key)...)
+	switch {
+	case err == nil:
+	case fsys.isNotFound(err):
+		return nil, &fs.PathError{
+			Op:   "tail",
+			Path: path,
+			Err:  fs.ErrNotExist,
+		}
+	case recoverInvalidRange(err):
+		// suffix range is not satisfiable for the empty object
+		return []byte{}, nil
+	default:
+		return nil, &fs.PathError{
+			Op:   "tail",
+			Path: path,
+			Err:  err,
+		}
+	}
+	defer val.Body.Close()
+
+	buf, err := io.ReadAll(val.Body)
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "tail",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	// S3-compatible stores might ignore the range, responding with entire content
+	if int64(len(buf)) > n {
+		buf = buf[int64(len(buf))-n:]
+	}
+
+	return buf, nil
+}
+
+// OpenLines opens the file as the sequence of lines, the object is streamed
+// line by line. The object is opened for each iteration and closed when lines
+// are exhausted or the consumer breaks. The line is valid until the next
+// iteration, copy it to retain.
+func (fsys *FileSystem[T]) OpenLines(path string) (iter.Seq2[[]byte, error], error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("open", path); err != nil {
+		return nil, err
+	}
+
+	seq := func(yield func([]byte, error) bool) {
+		fd := newReader(fsys, path)
+		defer fd.Close()
+
+		scanner := bufio.NewScanner(fd)
+		for scanner.Scan() {
+			if !yield(scanner.Bytes(), nil) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+
+	return seq, nil
+}
+
+// OpenRange opens the file for reading starting from the offset. Use IfRange
+// option for resumable reads, the read fails with ErrRangeIgnored if the
+// object has changed since the ETag was obtained.
+// Stat of the file reports the size of the entire object.
+func (fsys *FileSystem[T]) OpenRange(path string, offset int64, opt ...ReadOption) (fs.File, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("open", path); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		return nil, &fs.PathError{
+			Op:   "open",
+			Path: path,
+			Err:  fmt.Errorf("%w: negative offset %d", fs.ErrInvalid, offset),
+		}
+	}
+
+	var ropts ReadOpts
+	if err := opts.Apply(&ropts, opt); err != nil {
+		return nil, err
+	}
+
+	fd := newReader(fsys, path)
+	fd.offset = offset
+	fd.ifRange = ropts.ifRange
+
+	return fd, nil
+}
+
+// OpenWithInfo opens the file for reading conditionally on the known version
+// of the object (e.g. obtained by Stat). If the object's ETag matches the known
+// one, the reader reuses the known metadata and reading fails with
+// ErrNotModified, so that client uses its cached content. Otherwise it behaves
+// as Open.
+func (fsys *FileSystem[T]) OpenWithInfo(path string, known fs.FileInfo) (fs.File, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("open", path); err != nil {
+		return nil, err
+	}
+
+	fd := newReader(fsys, path)
+	if known != nil {
+		fd.known = known
+		fd.ifNoneMatch = etagOf(known)
+	}
+
+	return fd, nil
+}
+
 // Stat returns a FileInfo describing the file.
 // File system executes HeadObject S3 API call to obtain metadata.
 func (fsys *FileSystem[T]) Stat(path string) (fs.FileInfo, error) {
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	return fsys.StatCtx(ctx, path)
+}
+
+// StatCtx is Stat bound to the context, the I/O timeout of the file system
+// remains the upper bound of the call.
+func (fsys *FileSystem[T]) StatCtx(ctx context.Context, path string) (fs.FileInfo, error) {
+	path = fsys.canonical(path)
 	if err := RequireValidPath("stat", path); err != nil {
 		return nil, err
 	}
 
-	info := info[T]{path: path}
+	ctx, cancel := fsys.timeoutContext(ctx)
+	defer cancel()
 
 	if IsValidDir(path) {
-		info.mode = fs.ModeDir
-		return info, nil
+		if fsys.dirAggregate {
+			return fsys.statDirAggregate(ctx, path, path)
+		}
+		return info[T]{path: path, mode: fs.ModeDir}, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), fsys.timeout)
-	defer cancel()
+	stat, err := fsys.statFile(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) && !fsys.lenientDir {
+		// the path is directory given without trailing slash (e.g. WalkDir)
+		if found, _ := fsys.hasPrefix(ctx, path+"/"); found {
+			return nil, &fs.PathError{
+				Op:   "stat",
+				Path: path,
+				Err:  fmt.Errorf("%w: expected trailing '/' for directory, got %s", fs.ErrNotExist, path),
+			}
+		}
+	}
+
+	return stat, err
+}
+
+// obtains metadata of the file with HeadObject
+func (fsys *FileSystem[T]) statFile(ctx context.Context, path string) (fs.FileInfo, error) {
+	info := info[T]{path: path}
 
 	req := &s3.HeadObjectInput{
-		Bucket: aws.String(fsys.bucket),
-		Key:    info.s3Key(),
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 info.s3Key(),
 	}
 
-	val, err := fsys.api.HeadObject(ctx, req)
+	fsys.debug("HeadObject", req.Key)
+	val, err := fsys.api.HeadObject(ctx, req, fsys.regionOptions(req.Key)...)
 	if err != nil {
 		switch {
-		case recoverNotFound(err):
-			return nil, fs.ErrNotExist
+		case fsys.isNotFound(err) && fsys.lenientDir:
+			return fsys.statDir(ctx, path)
+		case fsys.isNotFound(err):
+			return nil, &fs.PathError{
+				Op:   "stat",
+				Path: path,
+				Err:  fs.ErrNotExist,
+			}
+		case recoverAccessDenied(err):
+			return nil, &fs.PathError{
+				Op:   "stat",
+				Path: path,
+				Err:  fmt.Errorf("%w: %w", ErrAccessDenied, err),
+			}
 		default:
 			return nil, &fs.PathError{
 				Op:   "stat",
@@ -147,8 +496,11 @@ func (fsys *FileSystem[T]) Stat(path string) (fs.FileInfo, error) {
 	info.time = aws.ToTime(val.LastModified)
 	info.attr = new(T)
 	fsys.codec.DecodeHeadOutput(val, info.attr)
+	if err := fsys.decodeTagging(ctx, "stat", path, info.attr); err != nil {
+		return nil, err
+	}
 
-	if fsys.signer != nil && fsys.codec.s != nil {
+	if fsys.signer != nil && fsys.autoPreSign && fsys.codec.s != nil {
 		if url, err := fsys.preSignGetUrl(info.s3Key()); err == nil {
 			fsys.codec.s.Put(info.attr, url)
 		}
@@ -157,6 +509,132 @@ func (fsys *FileSystem[T]) Stat(path string) (fs.FileInfo, error) {
 	return info, nil
 }
 
+// Exists probes the file with single HeadObject, it reports false without
+// error if the file does not exist.
+func (fsys *FileSystem[T]) Exists(path string) (bool, error) {
+	_, err := fsys.RawHead(path)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// RawHead returns unfiltered output of HeadObject for the file, it gives
+// access to response headers not mapped by the metadata type T.
+func (fsys *FileSystem[T]) RawHead(path string) (*s3.HeadObjectOutput, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("stat", path); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	req := &s3.HeadObjectInput{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3Key(path),
+	}
+
+	fsys.debug("HeadObject", req.Key)
+	val, err := fsys.api.HeadObject(ctx, req, fsys.regionOptions(req.Key)...)
+	if err != nil {
+		switch {
+		case fsys.isNotFound(err):
+			return nil, &fs.PathError{
+				Op:   "stat",
+				Path: path,
+				Err:  fmt.Errorf("%w: %w", fs.ErrNotExist, err),
+			}
+		case recoverAccessDenied(err):
+			return nil, &fs.PathError{
+				Op:   "stat",
+				Path: path,
+				Err:  fmt.Errorf("%w: %w", ErrAccessDenied, err),
+			}
+		default:
+			return nil, &fs.PathError{
+				Op:   "stat",
+				Path: path,
+				Err:  err,
+			}
+		}
+	}
+
+	return val, nil
+}
+
+// probes the prefix to check if path is a directory
+func (fsys *FileSystem[T]) statDir(ctx context.Context, path string) (fs.FileInfo, error) {
+	found, err := fsys.hasPrefix(ctx, path+"/")
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "stat",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	if !found {
+		return nil, fs.ErrNotExist
+	}
+
+	if fsys.dirAggregate {
+		return fsys.statDirAggregate(ctx, path, path+"/")
+	}
+
+	return info[T]{path: path, mode: fs.ModeDir}, nil
+}
+
+// probes the prefix with single object listing
+func (fsys *FileSystem[T]) hasPrefix(ctx context.Context, prefix string) (bool, error) {
+	req := &s3.ListObjectsV2Input{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		MaxKeys:             aws.Int32(1),
+		Prefix:              s3Key(prefix),
+	}
+
+	fsys.debug("ListObjectsV2", req.Prefix)
+	val, err := fsys.api.ListObjectsV2(ctx, req, fsys.regionOptions(req.Prefix)...)
+	if err != nil {
+		return false, err
+	}
+
+	return len(val.Contents) != 0, nil
+}
+
+// lists the prefix, summing size and taking the latest modification time
+func (fsys *FileSystem[T]) statDirAggregate(ctx context.Context, path, dir string) (fs.FileInfo, error) {
+	seq, err := openDir(fsys, dir).readAll(ctx)
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "stat",
+			Path: path,
+			Err:  errors.Unwrap(err),
+		}
+	}
+
+	stat := info[T]{path: path, mode: fs.ModeDir}
+	for _, el := range seq {
+		entry := el.(info[T])
+		stat.size += entry.size
+		if entry.time.After(stat.time) {
+			stat.time = entry.time
+		}
+	}
+
+	return stat, nil
+}
+
+// Atomic marks the file system as AtomicFS. S3 objects become visible only
+// after upload is completed by successful Close.
+func (fsys *FileSystem[T]) Atomic() {}
+
 // Returns file metadata of type T embedded into a FileInfo.
 func (fsys *FileSystem[T]) StatSys(stat fs.FileInfo) *T {
 	info, ok := stat.(info[T])
@@ -167,20 +645,40 @@ func (fsys *FileSystem[T]) StatSys(stat fs.FileInfo) *T {
 	return info.attr
 }
 
+// PreSignGetURL generates the pre-signed url for reading the file, the url
+// expires after WithPreSignUrlTTL. It does not check existence of the file.
+func (fsys *FileSystem[T]) PreSignGetURL(path string) (string, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("presign", path); err != nil {
+		return "", err
+	}
+
+	if fsys.signer == nil {
+		return "", &fs.PathError{
+			Op:   "presign",
+			Path: path,
+			Err:  fmt.Errorf("%w: url signer is not configured", errors.ErrUnsupported),
+		}
+	}
+
+	return fsys.preSignGetUrl(s3Key(path))
+}
+
 func (fsys *FileSystem[T]) preSignGetUrl(s3key *string) (string, error) {
 	req := &s3.GetObjectInput{
-		Bucket: aws.String(fsys.bucket),
-		Key:    s3key,
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3key,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), fsys.timeout)
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
 	defer cancel()
 
-	val, err := fsys.signer.PresignGetObject(ctx, req, s3.WithPresignExpires(fsys.ttlSignedUrl))
+	val, err := fsys.signer.PresignGetObject(ctx, req, s3.WithPresignExpires(fsys.ttlSignedUrl), fsys.presignRegionOptions(req.Key))
 	if err != nil {
 		return "", &fs.PathError{
 			Op:   "presign",
-			Path: "/" + aws.ToString(s3key),
+			Path: KeyToPath(aws.ToString(s3key)),
 			Err:  err,
 		}
 	}
@@ -192,10 +690,16 @@ func (fsys *FileSystem[T]) preSignGetUrl(s3key *string) (string, error) {
 // The classical file system organize data hierarchically into directories as
 // opposed to the flat storage structure of general purpose AWS S3.
 //
-// It assumes a directory if the path ends with `/`.
+// It assumes a directory if the path ends with `/`. Use WithLenientDir option
+// to accept directories without trailing `/`.
 //
 // It return path relative to pattern for all found object.
 func (fsys *FileSystem[T]) ReadDir(path string) ([]fs.DirEntry, error) {
+	path = fsys.canonical(path)
+	if fsys.lenientDir && IsValidFile(path) {
+		path = path + "/"
+	}
+
 	if err := RequireValidDir("readdir", path); err != nil {
 		return nil, err
 	}
@@ -205,29 +709,199 @@ func (fsys *FileSystem[T]) ReadDir(path string) ([]fs.DirEntry, error) {
 	return dd.ReadDir(-1)
 }
 
-// Glob returns the names of all files matching pattern.
-// The classical file system organize data hierarchically into directories as
-// opposed to the flat storage structure of general purpose AWS S3.
-//
-// It assumes a directory if the path ends with `/`.
-//
-// It return path relative to pattern for all found object.
-//
-// The pattern consists of S3 key prefix Golang regex. Its are split by `|`.
-func (fsys *FileSystem[T]) Glob(pattern string) ([]string, error) {
-	var reg *regexp.Regexp
-	var err error
-
-	pat := strings.SplitN(pattern, "|", 2)
-	if len(pat) == 2 {
-		reg, err = regexp.Compile(pat[1])
-		if err != nil {
-			return nil, &fs.PathError{
-				Op:   "glob",
-				Path: pattern,
-				Err:  err,
-			}
-		}
+// ReadDirCtx is ReadDir bound to the context, the I/O timeout of the file
+// system remains the upper bound of the listing.
+func (fsys *FileSystem[T]) ReadDirCtx(ctx context.Context, path string) ([]fs.DirEntry, error) {
+	path = fsys.canonical(path)
+	if fsys.lenientDir && IsValidFile(path) {
+		path = path + "/"
+	}
+
+	if err := RequireValidDir("readdir", path); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := fsys.timeoutContext(ctx)
+	defer cancel()
+
+	return openDir(fsys, path).readAll(ctx)
+}
+
+// ResolveEntry returns the absolute path of the entry read from the dir
+// (e.g. ReadDir), suitable for Open and Stat. The entry name is relative
+// to the dir, it is not a basename if keys are nested under the dir.
+func (fsys *FileSystem[T]) ResolveEntry(dir string, d fs.DirEntry) string {
+	dir = fsys.canonical(dir)
+	if !strings.HasSuffix(dir, "/") {
+		dir = dir + "/"
+	}
+
+	return dir + d.Name()
+}
+
+// ListModifiedSince reads the directory, returning only objects modified
+// after the timestamp. S3 listing does not filter by time, the entire prefix
+// is listed and filtered by the client.
+func (fsys *FileSystem[T]) ListModifiedSince(path string, since time.Time) ([]fs.DirEntry, error) {
+	seq, err := fsys.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	modified := make([]fs.DirEntry, 0)
+	for _, el := range seq {
+		fi, err := el.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		if fi.ModTime().After(since) {
+			modified = append(modified, el)
+		}
+	}
+
+	return modified, nil
+}
+
+// ListPrefixes returns directories (prefixes ending with "/") under the path
+// recursively down to leaves, objects are not reported. Each level costs
+// a delimited listing of the prefix, use WithMaxDepth to bound the recursion.
+func (fsys *FileSystem[T]) ListPrefixes(path string) ([]string, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidDir("listprefixes", path); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	seq := make([]string, 0)
+	if err := fsys.listPrefixes(ctx, path, 1, &seq); err != nil {
+		return nil, err
+	}
+
+	return seq, nil
+}
+
+func (fsys *FileSystem[T]) listPrefixes(ctx context.Context, path string, depth int, seq *[]string) error {
+	dd := openDir(fsys, path)
+	req := &s3.ListObjectsV2Input{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		MaxKeys:             aws.Int32(fsys.lslimit),
+		Prefix:              dd.s3Key(),
+		Delimiter:           aws.String("/"),
+	}
+
+	for {
+		val, err := dd.listObjects(ctx, req)
+		if err != nil {
+			return &fs.PathError{
+				Op:   "listprefixes",
+				Path: path,
+				Err:  err,
+			}
+		}
+
+		for _, el := range val.CommonPrefixes {
+			prefix := KeyToPath(aws.ToString(el.Prefix))
+			*seq = append(*seq, prefix)
+
+			if fsys.maxDepth <= 0 || depth < fsys.maxDepth {
+				if err := fsys.listPrefixes(ctx, prefix, depth+1, seq); err != nil {
+					return err
+				}
+			}
+		}
+
+		if val.NextContinuationToken == nil {
+			return nil
+		}
+
+		req.ContinuationToken = val.NextContinuationToken
+	}
+}
+
+// Glob returns the names of all files matching pattern, it follows
+// path.Match syntax (e.g. "/the/*/key") and returns absolute paths.
+// The file system lists the static prefix of the pattern and matches
+// each object path and its "directories" against the pattern.
+func (fsys *FileSystem[T]) Glob(pattern string) ([]string, error) {
+	pattern = fsys.canonical(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, &fs.PathError{
+			Op:   "glob",
+			Path: pattern,
+			Err:  err,
+		}
+	}
+
+	at := strings.IndexAny(pattern, `*?[\`)
+	if at == -1 {
+		at = len(pattern)
+	}
+	root := pattern[:strings.LastIndexByte(pattern[:at], '/')+1]
+
+	if err := RequireValidDir("glob", root); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	dir, err := openDir(fsys, root).readAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	seq := make([]string, 0)
+	for _, x := range dir {
+		name := x.Name()
+		for i := 1; i <= len(name); i++ {
+			if i != len(name) && name[i] != '/' {
+				continue
+			}
+
+			file := root + name[:i]
+			if _, has := seen[file]; has {
+				continue
+			}
+			seen[file] = struct{}{}
+
+			if ok, _ := path.Match(pattern, file); ok {
+				seq = append(seq, file)
+			}
+		}
+	}
+
+	sort.Strings(seq)
+	return seq, nil
+}
+
+// GlobRegexp returns the names of all files matching pattern.
+// The classical file system organize data hierarchically into directories as
+// opposed to the flat storage structure of general purpose AWS S3.
+//
+// It assumes a directory if the path ends with `/`.
+//
+// It return path relative to pattern for all found object.
+//
+// The pattern consists of S3 key prefix Golang regex. Its are split by `|`.
+func (fsys *FileSystem[T]) GlobRegexp(pattern string) ([]string, error) {
+	var reg *regexp.Regexp
+	var err error
+
+	pat := strings.SplitN(pattern, "|", 2)
+	if len(pat) == 2 {
+		reg, err = regexp.Compile(pat[1])
+		if err != nil {
+			return nil, &fs.PathError{
+				Op:   "glob",
+				Path: pattern,
+				Err:  err,
+			}
+		}
 	}
 
 	dir, err := fsys.ReadDir(pat[0])
@@ -244,21 +918,221 @@ func (fsys *FileSystem[T]) Glob(pattern string) ([]string, error) {
 	return seq, nil
 }
 
+// WriteBatch writes group of related objects. If any write fails, objects
+// already written by the batch are removed (compensating rollback). It is not
+// a transaction: readers might observe partially written batch and
+// the rollback is best-effort. The failure is reported as *BatchError, it
+// contains the failed write, rolled back and skipped items fail with
+// ErrBatchAborted. Items which are not rolled back remain succeeded, their
+// rollback failures are reported in BatchError.Rollback.
+func (fsys *FileSystem[T]) WriteBatch(items []WriteItem[T]) error {
+	var batch BatchError
+
+	for _, item := range items {
+		if err := RequireValidFile("create", fsys.canonical(item.Path)); err != nil {
+			batch.fail(item.Path, err)
+		}
+	}
+
+	if len(batch.Failed) != 0 {
+		return &batch
+	}
+
+	for i, item := range items {
+		if err := fsys.writeItem(item); err != nil {
+			batch.fail(item.Path, err)
+			for _, skipped := range items[i+1:] {
+				batch.fail(skipped.Path, ErrBatchAborted)
+			}
+			for _, written := range items[:i] {
+				if err := fsys.Remove(written.Path); err != nil {
+					batch.rollback(written.Path, err)
+					continue
+				}
+				batch.fail(written.Path, ErrBatchAborted)
+				batch.Succeeded--
+			}
+			break
+		}
+		batch.Succeeded++
+	}
+
+	return batch.orNil()
+}
+
+func (fsys *FileSystem[T]) writeItem(item WriteItem[T]) error {
+	fd, err := fsys.Create(item.Path, item.Attr)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fd, item.Body); err != nil {
+		fd.Cancel()
+		return err
+	}
+
+	return fd.Close()
+}
+
 // Remove object
 func (fsys *FileSystem[T]) Remove(path string) error {
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	return fsys.RemoveCtx(ctx, path)
+}
+
+// RemoveCtx is Remove bound to the context, the I/O timeout of the file
+// system remains the upper bound of the call.
+func (fsys *FileSystem[T]) RemoveCtx(ctx context.Context, path string) error {
+	path = fsys.canonical(path)
 	if err := RequireValidFile("remove", path); err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), fsys.timeout)
+	ctx, cancel := fsys.timeoutContext(ctx)
 	defer cancel()
 
+	if err := fsys.remove(ctx, path); err != nil {
+		return err
+	}
+
+	if fsys.dirMarkers {
+		return fsys.removeMarkers(ctx, path)
+	}
+
+	return nil
+}
+
+// RemoveAll removes files in batches of up to 1000 keys per DeleteObjects
+// request (e.g. clean up after WalkDir). Failures are reported as
+// *BatchError, no files are removed if any path is invalid.
+func (fsys *FileSystem[T]) RemoveAll(paths []string) error {
+	var batch BatchError
+
+	keys := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path = fsys.canonical(path)
+		if err := RequireValidFile("remove", path); err != nil {
+			batch.fail(path, err)
+			continue
+		}
+		keys = append(keys, path)
+	}
+
+	if len(batch.Failed) != 0 {
+		return &batch
+	}
+
+	for chunk := range slices.Chunk(keys, deleteObjectsLimit) {
+		fsys.removeChunk(chunk, &batch)
+	}
+
+	return batch.orNil()
+}
+
+// RemoveDir removes the directory and all files under it. The listing is
+// streamed, keys are deleted page by page with batched DeleteObjects.
+func (fsys *FileSystem[T]) RemoveDir(path string) error {
+	path = fsys.canonical(path)
+	if err := RequireValidDir("remove", path); err != nil {
+		return err
+	}
+
+	var batch BatchError
+
+	err := openDir(fsys, path).walk(fsys.ctx, func(page []types.Object) error {
+		keys := make([]string, len(page))
+		for i, el := range page {
+			keys[i] = KeyToPath(aws.ToString(el.Key))
+		}
+
+		for chunk := range slices.Chunk(keys, deleteObjectsLimit) {
+			fsys.removeChunk(chunk, &batch)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return batch.orNil()
+}
+
+// the limit of keys per DeleteObjects request
+const deleteObjectsLimit = 1000
+
+func (fsys *FileSystem[T]) removeChunk(paths []string, batch *BatchError) {
+	ctx, cancel := fsys.timeoutContext(fsys.ctx)
+	defer cancel()
+
+	req := &s3.DeleteObjectsInput{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Delete: &types.Delete{
+			Objects: make([]types.ObjectIdentifier, len(paths)),
+			Quiet:   aws.Bool(true),
+		},
+	}
+	for i, path := range paths {
+		req.Delete.Objects[i] = types.ObjectIdentifier{Key: s3Key(path)}
+	}
+
+	fsys.debug("DeleteObjects", req.Delete.Objects[0].Key, "count", len(paths))
+	val, err := fsys.api.DeleteObjects(ctx, req, fsys.regionOptions(req.Delete.Objects[0].Key)...)
+	if err != nil {
+		for _, path := range paths {
+			batch.fail(path, &fs.PathError{Op: "remove", Path: path, Err: err})
+		}
+		return
+	}
+
+	failed := map[string]struct{}{}
+	for _, e := range val.Errors {
+		path := KeyToPath(aws.ToString(e.Key))
+		failed[path] = struct{}{}
+		batch.fail(path, &fs.PathError{
+			Op:   "remove",
+			Path: path,
+			Err:  fmt.Errorf("%s: %s", aws.ToString(e.Code), aws.ToString(e.Message)),
+		})
+	}
+	batch.Succeeded += len(paths) - len(failed)
+
+	if !fsys.dirMarkers {
+		return
+	}
+
+	// markers are checked once per directory
+	dirs := map[string]struct{}{}
+	for _, path := range paths {
+		dir := path[:strings.LastIndexByte(path, '/')+1]
+		if _, has := failed[path]; has {
+			continue
+		}
+		if _, has := dirs[dir]; has {
+			continue
+		}
+		dirs[dir] = struct{}{}
+
+		// failure of markers is reported by the file, same as Remove does
+		if err := fsys.removeMarkers(ctx, path); err != nil {
+			batch.fail(path, err)
+			batch.Succeeded--
+		}
+	}
+}
+
+// removes the object with DeleteObject
+func (fsys *FileSystem[T]) remove(ctx context.Context, path string) error {
 	req := &s3.DeleteObjectInput{
-		Bucket: &fsys.bucket,
-		Key:    s3Key(path),
+		Bucket:              &fsys.bucket,
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3Key(path),
 	}
 
-	_, err := fsys.api.DeleteObject(ctx, req)
+	fsys.debug("DeleteObject", req.Key)
+	_, err := fsys.api.DeleteObject(ctx, req, fsys.regionOptions(req.Key)...)
 	if err != nil {
 		return &fs.PathError{
 			Op:   "remove",
@@ -270,9 +1144,170 @@ func (fsys *FileSystem[T]) Remove(path string) error {
 	return nil
 }
 
+// writes missing markers of parent directories, starting from the root
+func (fsys *FileSystem[T]) createMarkers(ctx context.Context, path string) error {
+	for _, dir := range parentDirs(path) {
+		req := &s3.HeadObjectInput{
+			Bucket:              aws.String(fsys.bucket),
+			ExpectedBucketOwner: fsys.expectedBucketOwner(),
+			Key:                 s3Key(dir),
+		}
+
+		fsys.debug("HeadObject", req.Key)
+		_, err := fsys.api.HeadObject(ctx, req, fsys.regionOptions(req.Key)...)
+		switch {
+		case err == nil:
+			continue
+		case !fsys.isNotFound(err):
+			return &fs.PathError{
+				Op:   "create",
+				Path: dir,
+				Err:  err,
+			}
+		}
+
+		put := &s3.PutObjectInput{
+			Bucket:              aws.String(fsys.bucket),
+			ExpectedBucketOwner: fsys.expectedBucketOwner(),
+			Key:                 s3Key(dir),
+			Body:                bytes.NewReader(nil),
+			ContentLength:       aws.Int64(0),
+		}
+
+		fsys.debug("PutObject", put.Key)
+		if _, err := fsys.api.PutObject(ctx, put, fsys.regionOptions(put.Key)...); err != nil {
+			return &fs.PathError{
+				Op:   "create",
+				Path: dir,
+				Err:  err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// removes markers of parent directories left empty, starting from the leaf
+func (fsys *FileSystem[T]) removeMarkers(ctx context.Context, path string) error {
+	dirs := parentDirs(path)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		req := &s3.ListObjectsV2Input{
+			Bucket:              aws.String(fsys.bucket),
+			ExpectedBucketOwner: fsys.expectedBucketOwner(),
+			MaxKeys:             aws.Int32(2),
+			Prefix:              s3Key(dir),
+		}
+
+		val, err := openDir(fsys, dir).listObjects(ctx, req)
+		if err != nil {
+			return &fs.PathError{
+				Op:   "remove",
+				Path: dir,
+				Err:  err,
+			}
+		}
+
+		marker := false
+		for _, el := range val.Contents {
+			if aws.ToString(el.Key) != aws.ToString(req.Prefix) {
+				// the directory is not empty
+				return nil
+			}
+			marker = true
+		}
+
+		if marker {
+			if err := fsys.remove(ctx, dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parent directories of the path, excluding root (e.g. "/a/", "/a/b/" for "/a/b/c")
+func parentDirs(path string) []string {
+	seq := make([]string, 0)
+	for i := 1; i < len(path); i++ {
+		if path[i] == '/' {
+			seq = append(seq, path[:i+1])
+		}
+	}
+
+	return seq
+}
+
+// OpenKey opens the object by S3 key as-is, bypassing path validation and
+// normalization, only a single leading "/" is stripped. It reaches legacy
+// keys, which do not conform to fs.ValidPath (e.g. "a//b" or "a/./b").
+// Use with care: the key is neither checked to be a file nor normalized.
+func (fsys *FileSystem[T]) OpenKey(key string) (fs.File, error) {
+	path, err := rawKeyPath("open", key)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := newReader(fsys, path)
+	fd.rawKey = true
+
+	return fd, nil
+}
+
+// StatKey is Stat of the object by S3 key as-is, see OpenKey.
+func (fsys *FileSystem[T]) StatKey(key string) (fs.FileInfo, error) {
+	path, err := rawKeyPath("stat", key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	return fsys.statFile(ctx, path)
+}
+
+// RemoveKey is Remove of the object by S3 key as-is, see OpenKey.
+func (fsys *FileSystem[T]) RemoveKey(key string) error {
+	path, err := rawKeyPath("remove", key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	return fsys.remove(ctx, path)
+}
+
+// maps raw S3 key to the path, the key is only required to be non-empty
+func rawKeyPath(op, key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if len(key) == 0 {
+		return "", &fs.PathError{
+			Op:   op,
+			Path: key,
+			Err:  fmt.Errorf("%w: empty key", fs.ErrInvalid),
+		}
+	}
+
+	return "/" + key, nil
+}
+
 // Copy object from source location to the target.
 // The target shall be absolute s3://bucket/key url.
 func (fsys *FileSystem[T]) Copy(source, target string) error {
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	return fsys.CopyCtx(ctx, source, target)
+}
+
+// CopyCtx is Copy bound to the context, the I/O timeout of the file system
+// remains the upper bound of the call.
+func (fsys *FileSystem[T]) CopyCtx(ctx context.Context, source, target string) error {
+	source = fsys.canonical(source)
 	if err := RequireValidPath("copy", source); err != nil {
 		return err
 	}
@@ -285,16 +1320,19 @@ func (fsys *FileSystem[T]) Copy(source, target string) error {
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), fsys.timeout)
+	ctx, cancel := fsys.timeoutContext(ctx)
 	defer cancel()
 
 	req := &s3.CopyObjectInput{
-		Bucket:     &fsys.bucket,
-		Key:        s3Key(source),
-		CopySource: aws.String(target[5:]),
+		Bucket:              &fsys.bucket,
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3Key(source),
+		CopySource:          aws.String(target[5:]),
 	}
+	fsys.encryptCopy(req)
 
-	_, err := fsys.api.CopyObject(ctx, req)
+	fsys.debug("CopyObject", req.Key, "target", target)
+	_, err := fsys.api.CopyObject(ctx, req, fsys.regionOptions(req.Key)...)
 	if err != nil {
 		return &fs.PathError{
 			Op:   "copy",
@@ -306,20 +1344,324 @@ func (fsys *FileSystem[T]) Copy(source, target string) error {
 	return nil
 }
 
+// server-side encryption of the copy, same as objects written by the file system
+func (fsys *FileSystem[T]) encryptCopy(req *s3.CopyObjectInput) {
+	if fsys.sse != "" {
+		req.ServerSideEncryption = fsys.sse
+	}
+
+	if fsys.sseKMSKeyID != "" {
+		req.SSEKMSKeyId = aws.String(fsys.sseKMSKeyID)
+	}
+}
+
+// CopyWithProgress copies the object by streaming it through the client,
+// reporting the progress to fn. It is the fallback for Copy when server-side
+// copy is not possible. The target is either the absolute path within the file
+// system or s3://bucket/key. The metadata of source object is preserved.
+func (fsys *FileSystem[T]) CopyWithProgress(source, target string, fn func(copied, total int64)) error {
+	source = fsys.canonical(source)
+	if err := RequireValidFile("copy", source); err != nil {
+		return err
+	}
+
+	dst, path, err := fsys.copyTarget(target)
+	if err != nil {
+		return err
+	}
+
+	fi, err := fsys.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	r, err := fsys.Open(source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dst.Create(path, fsys.StatSys(fi))
+	if err != nil {
+		return err
+	}
+
+	pr := &progressReader{r: r, total: fi.Size(), fn: fn}
+	if _, err := io.Copy(w, pr); err != nil {
+		w.Cancel()
+		return err
+	}
+
+	return w.Close()
+}
+
+// Rename moves the file to the target path within the bucket. S3 has no
+// rename, the object is copied (CopyObject) and then the source is removed.
+// The operation is not atomic, the object is observable at both paths in
+// between. If removal fails the copy remains at the target.
+func (fsys *FileSystem[T]) Rename(source, target string) error {
+	source = fsys.canonical(source)
+	if err := RequireValidFile("rename", source); err != nil {
+		return err
+	}
+
+	target = fsys.canonical(target)
+	if err := RequireValidFile("rename", target); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	req := &s3.CopyObjectInput{
+		Bucket:                    aws.String(fsys.bucket),
+		ExpectedBucketOwner:       fsys.expectedBucketOwner(),
+		Key:                       s3Key(target),
+		CopySource:                aws.String(fsys.bucket + source),
+		ExpectedSourceBucketOwner: fsys.expectedBucketOwner(),
+	}
+	fsys.encryptCopy(req)
+
+	fsys.debug("CopyObject", req.Key, "source", source)
+	if _, err := fsys.api.CopyObject(ctx, req, fsys.regionOptions(req.Key)...); err != nil {
+		if fsys.isNotFound(err) {
+			err = fs.ErrNotExist
+		}
+
+		return &fs.PathError{
+			Op:   "rename",
+			Path: source,
+			Err:  err,
+		}
+	}
+
+	if fsys.dirMarkers {
+		if err := fsys.createMarkers(ctx, target); err != nil {
+			return err
+		}
+	}
+
+	if err := fsys.remove(ctx, source); err != nil {
+		return err
+	}
+
+	if fsys.dirMarkers {
+		return fsys.removeMarkers(ctx, source)
+	}
+
+	return nil
+}
+
+// resolves target of copy into file system and path
+func (fsys *FileSystem[T]) copyTarget(target string) (*FileSystem[T], string, error) {
+	if !strings.HasPrefix(target, "s3://") {
+		target = fsys.canonical(target)
+		if err := RequireValidFile("copy", target); err != nil {
+			return nil, "", err
+		}
+		return fsys, target, nil
+	}
+
+	bucket, key, _ := strings.Cut(target[5:], "/")
+	path := KeyToPath(key)
+	if err := RequireValidFile("copy", path); err != nil || len(bucket) == 0 {
+		return nil, "", &fs.PathError{
+			Op:   "copy",
+			Path: target,
+			Err:  fmt.Errorf("%w: expected s3://bucket/key", fs.ErrInvalid),
+		}
+	}
+
+	return &FileSystem[T]{Opts: fsys.Opts, bucket: bucket, codec: fsys.codec, ctx: fsys.ctx, cancel: fsys.cancel}, path, nil
+}
+
+type progressReader struct {
+	r      io.Reader
+	copied int64
+	total  int64
+	fn     func(copied, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.copied += int64(n)
+		if r.fn != nil {
+			r.fn(r.copied, r.total)
+		}
+	}
+	return n, err
+}
+
+// UpdateMeta replaces metadata of the existing object without rewriting its
+// body. The object is copied to itself with metadata encoded from attr,
+// metadata not defined by attr is discarded.
+func (fsys *FileSystem[T]) UpdateMeta(path string, attr *T) error {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("updatemeta", path); err != nil {
+		return err
+	}
+
+	if _, err := fsys.Stat(path); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	meta := &s3.PutObjectInput{Metadata: make(map[string]string)}
+	fsys.encodeMetadata(ctx, attr, meta)
+
+	req := &s3.CopyObjectInput{
+		Bucket:                    aws.String(fsys.bucket),
+		ExpectedBucketOwner:       fsys.expectedBucketOwner(),
+		Key:                       s3Key(path),
+		CopySource:                aws.String(fsys.bucket + path),
+		ExpectedSourceBucketOwner: fsys.expectedBucketOwner(),
+		MetadataDirective:         types.MetadataDirectiveReplace,
+		CacheControl:              meta.CacheControl,
+		ContentDisposition:        meta.ContentDisposition,
+		ContentEncoding:           meta.ContentEncoding,
+		ContentLanguage:           meta.ContentLanguage,
+		ContentType:               meta.ContentType,
+		Expires:                   meta.Expires,
+		StorageClass:              meta.StorageClass,
+		Metadata:                  meta.Metadata,
+	}
+	fsys.encryptCopy(req)
+
+	fsys.debug("CopyObject", req.Key, "metadata", "replace")
+	_, err := fsys.api.CopyObject(ctx, req, fsys.regionOptions(req.Key)...)
+	if err != nil {
+		return &fs.PathError{
+			Op:   "updatemeta",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
+// per request options of S3 client, the region is selected by the longest
+// prefix of the key defined with WithRegionForPrefix.
+func (fsys *FileSystem[T]) regionOptions(key *string) []func(*s3.Options) {
+	region := fsys.regionFor(key)
+	if len(region) == 0 {
+		return nil
+	}
+
+	return []func(*s3.Options){func(o *s3.Options) { o.Region = region }}
+}
+
+// region of the longest matching prefix (WithRegionForPrefix), if any
+func (fsys *FileSystem[T]) regionFor(key *string) string {
+	region, prefix := "", ""
+	for p, r := range fsys.regions {
+		if strings.HasPrefix(aws.ToString(key), p) && len(p) >= len(prefix) {
+			region, prefix = r, p
+		}
+	}
+
+	return region
+}
+
+func (fsys *FileSystem[T]) presignRegionOptions(key *string) func(*s3.PresignOptions) {
+	return func(o *s3.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, fsys.regionOptions(key)...)
+	}
+}
+
+func (fsys *FileSystem[T]) waiterRegionOptions(key *string) func(*s3.ObjectExistsWaiterOptions) {
+	return func(o *s3.ObjectExistsWaiterOptions) {
+		o.ClientOptions = append(o.ClientOptions, fsys.regionOptions(key)...)
+	}
+}
+
+func (fsys *FileSystem[T]) expectedBucketOwner() *string {
+	if len(fsys.bucketOwner) == 0 {
+		return nil
+	}
+
+	return aws.String(fsys.bucketOwner)
+}
+
+// decodes object tags with GetObjectTagging, the request is made only
+// if the type T declares tags (see `tag:"..."`)
+func (fsys *FileSystem[T]) decodeTagging(ctx context.Context, op, path string, attr *T) error {
+	if !fsys.codec.HasTagging() {
+		return nil
+	}
+
+	req := &s3.GetObjectTaggingInput{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3Key(path),
+	}
+
+	fsys.debug("GetObjectTagging", req.Key)
+	val, err := fsys.api.GetObjectTagging(ctx, req, fsys.regionOptions(req.Key)...)
+	if err != nil {
+		return &fs.PathError{
+			Op:   op,
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	fsys.codec.DecodeTagging(val.TagSet, attr)
+	return nil
+}
+
+// encodes attributes, context values and default metadata into request
+func (fsys *FileSystem[T]) encodeMetadata(ctx context.Context, attr *T, req *s3.PutObjectInput) {
+	fsys.codec.EncodePutInput(attr, req)
+	fsys.codec.EncodeTagging(attr, req)
+
+	for key, ctxKey := range fsys.ctxMeta {
+		if len(req.Metadata[key]) == 0 {
+			if val := ctx.Value(ctxKey); val != nil {
+				req.Metadata[key] = fmt.Sprint(val)
+			}
+		}
+	}
+
+	for key, val := range fsys.defaultMeta {
+		if len(req.Metadata[key]) == 0 {
+			req.Metadata[key] = val
+		}
+	}
+}
+
 // Wait for timeout until path exists
 func (fsys *FileSystem[T]) Wait(path string, timeout time.Duration) error {
+	path = fsys.canonical(path)
 	if err := RequireValidFile("wait", path); err != nil {
 		return err
 	}
 
-	waiter := s3.NewObjectExistsWaiter(fsys.api)
+	// the object already exists, skip polling
+	_, err := fsys.Stat(path)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	waiter := fsys.waiter
+	if waiter == nil {
+		waiter = s3.NewObjectExistsWaiter(fsys.api)
+	}
 
 	req := &s3.HeadObjectInput{
-		Bucket: aws.String(fsys.bucket),
-		Key:    s3Key(path),
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Key:                 s3Key(path),
 	}
 
-	err := waiter.Wait(context.Background(), req, timeout)
+	fsys.debug("HeadObject", req.Key, "timeout", timeout)
+	err = waiter.Wait(fsys.ctx, req, timeout, fsys.waiterRegionOptions(req.Key))
 	if err != nil {
 		return &fs.PathError{
 			Op:   "wait",
@@ -331,18 +1673,193 @@ func (fsys *FileSystem[T]) Wait(path string, timeout time.Duration) error {
 	return nil
 }
 
+// ListIncompleteUploads returns multipart uploads under the path prefix that
+// are neither completed nor aborted. Orphaned uploads are left by failed or
+// cancelled writes, S3 charges for their storage.
+func (fsys *FileSystem[T]) ListIncompleteUploads(prefix string) ([]MultipartUpload, error) {
+	prefix = fsys.canonical(prefix)
+	if err := RequireValidPath("uploads", prefix); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	req := &s3.ListMultipartUploadsInput{
+		Bucket:              aws.String(fsys.bucket),
+		ExpectedBucketOwner: fsys.expectedBucketOwner(),
+		Prefix:              s3Key(prefix),
+	}
+
+	seq := make([]MultipartUpload, 0)
+	for {
+		fsys.debug("ListMultipartUploads", req.Prefix)
+		val, err := fsys.api.ListMultipartUploads(ctx, req, fsys.regionOptions(req.Prefix)...)
+		if err != nil {
+			return nil, &fs.PathError{
+				Op:   "uploads",
+				Path: prefix,
+				Err:  err,
+			}
+		}
+
+		for _, el := range val.Uploads {
+			seq = append(seq, MultipartUpload{
+				Path:      KeyToPath(aws.ToString(el.Key)),
+				UploadID:  aws.ToString(el.UploadId),
+				Initiated: aws.ToTime(el.Initiated),
+			})
+		}
+
+		if !aws.ToBool(val.IsTruncated) {
+			return seq, nil
+		}
+
+		req.KeyMarker = val.NextKeyMarker
+		req.UploadIdMarker = val.NextUploadIdMarker
+	}
+}
+
+// AbortIncompleteUploads aborts multipart uploads initiated before the given
+// duration, it returns number of aborted uploads.
+func (fsys *FileSystem[T]) AbortIncompleteUploads(olderThan time.Duration) (int, error) {
+	seq, err := fsys.ListIncompleteUploads("/")
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	n := 0
+	before := time.Now().Add(-olderThan)
+	for _, upload := range seq {
+		if !upload.Initiated.Before(before) {
+			continue
+		}
+
+		req := &s3.AbortMultipartUploadInput{
+			Bucket:              aws.String(fsys.bucket),
+			ExpectedBucketOwner: fsys.expectedBucketOwner(),
+			Key:                 s3Key(upload.Path),
+			UploadId:            aws.String(upload.UploadID),
+		}
+
+		fsys.debug("AbortMultipartUpload", req.Key)
+		if _, err := fsys.api.AbortMultipartUpload(ctx, req, fsys.regionOptions(req.Key)...); err != nil {
+			return n, &fs.PathError{
+				Op:   "abort",
+				Path: upload.Path,
+				Err:  err,
+			}
+		}
+		n++
+	}
+
+	return n, nil
+}
+
 //------------------------------------------------------------------------------
 
+// normalizes path if configured
+func (fsys *FileSystem[T]) canonical(path string) string {
+	if !fsys.normalize {
+		return path
+	}
+
+	return NormalizePath(path)
+}
+
+// context of I/O bounded by the I/O timeout, it is cancelled by either
+// the context of caller or the context of file system
+func (fsys *FileSystem[T]) timeoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	bounded, cancel := context.WithTimeout(ctx, fsys.timeout)
+	if ctx == fsys.ctx {
+		return bounded, cancel
+	}
+
+	stop := context.AfterFunc(fsys.ctx, cancel)
+	return bounded, func() { stop(); cancel() }
+}
+
+// logs S3 request at debug level if logger is configured
+func (fsys *FileSystem[T]) debug(op string, key *string, args ...any) {
+	if fsys.logger == nil {
+		return
+	}
+
+	attrs := append([]any{"op", op, "bucket", fsys.bucket, "key", aws.ToString(key)}, args...)
+	fsys.logger.Debug("s3 request", attrs...)
+}
+
+// not found is either detected by WithNotFoundDetector or S3 error
+func (fsys *FileSystem[T]) isNotFound(err error) bool {
+	if fsys.notFound != nil && fsys.notFound(err) {
+		return true
+	}
+
+	return recoverNoSuchKey(err) || recoverNotFound(err)
+}
+
 func recoverNoSuchKey(err error) bool {
 	var e interface{ ErrorCode() string }
+	if errors.As(err, &e) && e.ErrorCode() == "NoSuchKey" {
+		return true
+	}
 
-	ok := errors.As(err, &e)
-	return ok && e.ErrorCode() == "NoSuchKey"
+	var s interface{ HTTPStatusCode() int }
+	return errors.As(err, &s) && s.HTTPStatusCode() == 404
 }
 
 func recoverNotFound(err error) bool {
 	var e interface{ ErrorCode() string }
+	if errors.As(err, &e) && e.ErrorCode() == "NotFound" {
+		return true
+	}
+
+	var s interface{ HTTPStatusCode() int }
+	return errors.As(err, &s) && s.HTTPStatusCode() == 404
+}
+
+func recoverInvalidRange(err error) bool {
+	var e interface{ ErrorCode() string }
+	if errors.As(err, &e) && e.ErrorCode() == "InvalidRange" {
+		return true
+	}
+
+	var s interface{ HTTPStatusCode() int }
+	return errors.As(err, &s) && s.HTTPStatusCode() == 416
+}
+
+func recoverAccessDenied(err error) bool {
+	var e interface{ ErrorCode() string }
+	if errors.As(err, &e) {
+		switch e.ErrorCode() {
+		case "AccessDenied", "Forbidden":
+			return true
+		}
+	}
+
+	var s interface{ HTTPStatusCode() int }
+	return errors.As(err, &s) && s.HTTPStatusCode() == 403
+}
+
+func recoverNotModified(err error) bool {
+	var e interface{ ErrorCode() string }
+	if errors.As(err, &e) && e.ErrorCode() == "NotModified" {
+		return true
+	}
+
+	var s interface{ HTTPStatusCode() int }
+	return errors.As(err, &s) && s.HTTPStatusCode() == 304
+}
+
+func recoverPreconditionFailed(err error) bool {
+	var e interface{ ErrorCode() string }
+	if errors.As(err, &e) && e.ErrorCode() == "PreconditionFailed" {
+		return true
+	}
 
-	ok := errors.As(err, &e)
-	return ok && e.ErrorCode() == "NotFound"
+	var s interface{ HTTPStatusCode() int }
+	return errors.As(err, &s) && s.HTTPStatusCode() == 412
 }
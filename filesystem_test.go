@@ -9,18 +9,34 @@
 package stream_test
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/fogfish/it/v2"
 	"github.com/fogfish/stream"
 	"github.com/fogfish/stream/internal/mocks"
@@ -36,14 +52,15 @@ var (
 	expires      = time.Date(2025, 05, 11, 18, 04, 30, 0, time.UTC)
 	note         = Note{
 		SystemMetadata: stream.SystemMetadata{
-			CacheControl:    "no-cache",
-			ContentEncoding: "identity",
-			ContentLanguage: "en",
-			ContentType:     "text/plain",
-			Expires:         &expires,
-			ETag:            "cafe",
-			LastModified:    &modified,
-			StorageClass:    "GLACIER",
+			CacheControl:       "no-cache",
+			ContentDisposition: "attachment; filename=key.txt",
+			ContentEncoding:    "identity",
+			ContentLanguage:    "en",
+			ContentType:        "text/plain",
+			Expires:            &expires,
+			ETag:               "cafe",
+			LastModified:       &modified,
+			StorageClass:       "GLACIER",
 		},
 		Author:  "fogfish",
 		Chapter: "streaming",
@@ -53,15 +70,16 @@ var (
 		Mock: mocks.Mock[s3.HeadObjectOutput]{
 			ExpectKey: file[1:],
 			ReturnVal: &s3.HeadObjectOutput{
-				ContentLength:   aws.Int64(size),
-				ContentType:     aws.String("text/plain"),
-				LastModified:    aws.Time(modified),
-				CacheControl:    aws.String("no-cache"),
-				ContentEncoding: aws.String("identity"),
-				ContentLanguage: aws.String("en"),
-				Expires:         aws.Time(expires),
-				ETag:            aws.String("cafe"),
-				StorageClass:    types.StorageClassGlacier,
+				ContentLength:      aws.Int64(size),
+				ContentType:        aws.String("text/plain"),
+				LastModified:       aws.Time(modified),
+				CacheControl:       aws.String("no-cache"),
+				ContentDisposition: aws.String("attachment; filename=key.txt"),
+				ContentEncoding:    aws.String("identity"),
+				ContentLanguage:    aws.String("en"),
+				Expires:            aws.Time(expires),
+				ETag:               aws.String("cafe"),
+				StorageClass:       types.StorageClassGlacier,
 				Metadata: map[string]string{
 					"author":  "fogfish",
 					"chapter": "streaming",
@@ -83,20 +101,28 @@ var (
 		},
 	}
 
+	s3HeadObjectAccessDenied = mocks.HeadObject{
+		Mock: mocks.Mock[s3.HeadObjectOutput]{
+			ExpectKey: file[1:],
+			ReturnErr: &smithy.GenericAPIError{Code: "Forbidden"},
+		},
+	}
+
 	s3GetObject = mocks.GetObject{
 		Mock: mocks.Mock[s3.GetObjectOutput]{
 			ExpectKey: file[1:],
 			ReturnVal: &s3.GetObjectOutput{
-				Body:            io.NopCloser(bytes.NewBuffer([]byte(content))),
-				ContentLength:   aws.Int64(size),
-				ContentType:     aws.String("text/plain"),
-				LastModified:    aws.Time(modified),
-				CacheControl:    aws.String("no-cache"),
-				ContentEncoding: aws.String("identity"),
-				ContentLanguage: aws.String("en"),
-				Expires:         aws.Time(expires),
-				ETag:            aws.String("cafe"),
-				StorageClass:    types.StorageClassGlacier,
+				Body:               io.NopCloser(bytes.NewBuffer([]byte(content))),
+				ContentLength:      aws.Int64(size),
+				ContentType:        aws.String("text/plain"),
+				LastModified:       aws.Time(modified),
+				CacheControl:       aws.String("no-cache"),
+				ContentDisposition: aws.String("attachment; filename=key.txt"),
+				ContentEncoding:    aws.String("identity"),
+				ContentLanguage:    aws.String("en"),
+				Expires:            aws.Time(expires),
+				ETag:               aws.String("cafe"),
+				StorageClass:       types.StorageClassGlacier,
 				Metadata: map[string]string{
 					"author":  "fogfish",
 					"chapter": "streaming",
@@ -118,6 +144,13 @@ var (
 		},
 	}
 
+	s3GetObjectAccessDenied = mocks.GetObject{
+		Mock: mocks.Mock[s3.GetObjectOutput]{
+			ExpectKey: file[1:],
+			ReturnErr: &smithy.GenericAPIError{Code: "AccessDenied"},
+		},
+	}
+
 	s3PutObject = mocks.PutObject{
 		Mock: mocks.Mock[manager.UploadOutput]{
 			ExpectKey: file[1:],
@@ -224,698 +257,4732 @@ func TestNew(t *testing.T) {
 	it.Then(t).Should(it.Nil(err)).ShouldNot(it.Nil(s3fs))
 }
 
-func TestReadWrite(t *testing.T) {
-	t.Run("File/Read", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+func TestClose(t *testing.T) {
+	delay := 5 * time.Second
+	s3HeadObjectSlow := mocks.HeadObject{
+		Mock: mocks.Mock[s3.HeadObjectOutput]{
+			Delay:     &delay,
+			ExpectKey: file[1:],
+			ReturnVal: s3HeadObject.ReturnVal,
+		},
+	}
+
+	t.Run("Close", func(t *testing.T) {
+		s3fs, err := stream.NewWithContext[struct{}](context.Background(), "test",
+			stream.WithS3(s3HeadObjectSlow),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
-		fd, err := s3fs.Open(file)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			s3fs.Close()
+		}()
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.True(errors.Is(err, context.Canceled)))
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s3fs, err := stream.NewWithContext[struct{}](ctx, "test",
+			stream.WithS3(s3HeadObjectSlow),
+		)
 		it.Then(t).Must(it.Nil(err))
 
-		buf, err := io.ReadAll(fd)
+		cancel()
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.True(errors.Is(err, context.Canceled)))
+	})
+}
+
+func TestContextVariants(t *testing.T) {
+	delay := 5 * time.Second
+	slow := func(m mocks.Mock[s3.HeadObjectOutput]) mocks.Mock[s3.HeadObjectOutput] {
+		m.Delay = &delay
+		return m
+	}
+
+	deadline := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 10*time.Millisecond)
+	}
+
+	t.Run("StatCtx", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3HeadObject))
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := s3fs.StatCtx(context.Background(), file)
 		it.Then(t).Should(
 			it.Nil(err),
-			it.Equal(string(buf), content),
+			it.Equal(fi.Size(), 12),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("StatCtx/Deadline", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{Mock: slow(s3HeadObject.Mock)}),
+		)
 		it.Then(t).Must(it.Nil(err))
+
+		ctx, cancel := deadline()
+		defer cancel()
+
+		_, err = s3fs.StatCtx(ctx, file)
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
 	})
 
-	t.Run("Dir/Read", func(t *testing.T) {
+	t.Run("StatCtx/Timeout", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+			stream.WithS3(mocks.HeadObject{Mock: slow(s3HeadObject.Mock)}),
+			stream.WithIOTimeout(10*time.Millisecond),
 		)
-		it.Then(t).Should(it.Nil(err))
-
-		fd, err := s3fs.Open(dir)
 		it.Then(t).Must(it.Nil(err))
 
-		it.Then(t).Should(
-			it.Error(io.ReadAll(fd)),
+		_, err = s3fs.StatCtx(context.Background(), file)
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
+	})
+
+	t.Run("StatCtx/Close", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{Mock: slow(s3HeadObject.Mock)}),
 		)
+		it.Then(t).Must(it.Nil(err))
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			s3fs.Close()
+		}()
+
+		_, err = s3fs.StatCtx(context.Background(), file)
+		it.Then(t).Should(it.True(errors.Is(err, context.Canceled)))
 	})
 
-	t.Run("File/Write", func(t *testing.T) {
+	t.Run("OpenCtx", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3PutObject),
-			stream.WithS3Upload(s3PutObject),
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+				Content: content,
+			}),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
-		fd, err := s3fs.Create(file, nil)
+		fd, err := s3fs.OpenCtx(context.Background(), file)
 		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
 
-		n, err := io.WriteString(fd, content)
+		buf, err := io.ReadAll(fd)
 		it.Then(t).Should(
 			it.Nil(err),
-			it.Equal(n, len(content)),
+			it.Equal(string(buf), content),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("OpenCtx/Cancel", func(t *testing.T) {
+		m := s3GetObject
+		m.Delay = &delay
+		s3fs, err := stream.NewFS("test", stream.WithS3(m))
 		it.Then(t).Must(it.Nil(err))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		fd, err := s3fs.OpenCtx(ctx, file)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(it.True(errors.Is(err, context.Canceled)))
 	})
 
-	t.Run("File/Write/Cancel", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3PutObject),
-			stream.WithS3Upload(s3PutObject),
+	t.Run("OpenCtx/Dir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3ListObject))
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.OpenCtx(context.Background(), dir)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := fd.(fs.ReadDirFile).ReadDir(-1)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(seq), 3),
 		)
-		it.Then(t).Should(it.Nil(err))
+	})
 
-		fd, err := s3fs.Create(file, nil)
+	t.Run("ReadDirCtx", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3ListObject))
 		it.Then(t).Must(it.Nil(err))
 
-		n, err := io.WriteString(fd, content)
+		seq, err := s3fs.ReadDirCtx(context.Background(), dir)
 		it.Then(t).Should(
 			it.Nil(err),
-			it.Equal(n, len(content)),
+			it.Equal(len(seq), 3),
 		)
+	})
 
-		err = fd.Cancel()
+	t.Run("ReadDirCtx/Error/NotDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3ListObject))
 		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.ReadDirCtx(context.Background(), file)
+		it.Then(t).Should(it.Fail(func() error { return err }))
 	})
 
-	t.Run("File/Read/Error/InvalidPath", func(t *testing.T) {
+	t.Run("ReadDirCtx/Timeout", func(t *testing.T) {
+		m := s3ListObject
+		m.Delay = &delay
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+			stream.WithS3(m),
+			stream.WithIOTimeout(10*time.Millisecond),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
-		it.Then(t).Should(
-			it.Error(s3fs.Open("invalid..key/")),
-		)
+		_, err = s3fs.ReadDirCtx(context.Background(), dir)
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
 	})
 
-	t.Run("File/Read/Error", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObjectError),
-		)
+	t.Run("RemoveCtx", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3DeleteObject))
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveCtx(context.Background(), file)
 		it.Then(t).Should(it.Nil(err))
+	})
 
-		fd, err := s3fs.Open(file)
+	t.Run("RemoveCtx/Deadline", func(t *testing.T) {
+		m := s3DeleteObject
+		m.Delay = &delay
+		s3fs, err := stream.NewFS("test", stream.WithS3(m))
 		it.Then(t).Must(it.Nil(err))
 
-		it.Then(t).Should(
-			it.Error(io.ReadAll(fd)),
-		)
+		ctx, cancel := deadline()
+		defer cancel()
+
+		err = s3fs.RemoveCtx(ctx, file)
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
 	})
 
-	t.Run("File/Read/Error/NotFound", func(t *testing.T) {
+	t.Run("RemoveCtx/Timeout", func(t *testing.T) {
+		m := s3DeleteObject
+		m.Delay = &delay
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObjectNotFound),
+			stream.WithS3(m),
+			stream.WithIOTimeout(10*time.Millisecond),
 		)
-		it.Then(t).Should(it.Nil(err))
-
-		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		_, err = io.ReadAll(fd)
-		it.Then(t).Should(
-			it.True(errors.Is(err, fs.ErrNotExist)),
-		)
+		err = s3fs.RemoveCtx(context.Background(), file)
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
 	})
 
-	t.Run("File/Write/Error", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3PutObject),
-			stream.WithS3Upload(s3PutObjectError),
-		)
+	t.Run("CopyCtx", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3CopyObject))
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.CopyCtx(context.Background(), file, "s3://test/file")
 		it.Then(t).Should(it.Nil(err))
+	})
 
-		fd, err := s3fs.Create(file, nil)
+	t.Run("CopyCtx/Deadline", func(t *testing.T) {
+		m := s3CopyObject
+		m.Delay = &delay
+		s3fs, err := stream.NewFS("test", stream.WithS3(m))
 		it.Then(t).Must(it.Nil(err))
 
-		it.Then(t).Should(
-			it.Error(io.WriteString(fd, content)),
-		)
+		ctx, cancel := deadline()
+		defer cancel()
 
-		it.Then(t).Should(
-			it.Fail(fd.Close),
-		)
+		err = s3fs.CopyCtx(ctx, file, "s3://test/file")
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
 	})
 
-	t.Run("File/Write/Error/InvalidPath", func(t *testing.T) {
+	t.Run("CopyCtx/Timeout", func(t *testing.T) {
+		m := s3CopyObject
+		m.Delay = &delay
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+			stream.WithS3(m),
+			stream.WithIOTimeout(10*time.Millisecond),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
-		it.Then(t).Should(
-			it.Error(s3fs.Create("invalid..key/", nil)),
-		)
+		err = s3fs.CopyCtx(context.Background(), file, "s3://test/file")
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
 	})
+}
 
-	t.Run("File/Write/Error/Directory", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
-		)
-		it.Then(t).Should(it.Nil(err))
+func TestDebugLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
-		it.Then(t).Should(
-			it.Error(s3fs.Create(dir, nil)),
-		)
-	})
+	s3fs, err := stream.NewFS("test",
+		stream.WithS3(s3HeadObject),
+		stream.WithDebugLogger(log),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	_, err = s3fs.Stat(file)
+	it.Then(t).Must(it.Nil(err))
+	it.Then(t).Should(
+		it.True(strings.Contains(buf.String(), "op=HeadObject")),
+		it.True(strings.Contains(buf.String(), "bucket=test")),
+		it.True(strings.Contains(buf.String(), "key="+file[1:])),
+	)
 }
 
-func TestWalk(t *testing.T) {
-	t.Run("ReadDir", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3ListObject),
+func TestMetrics(t *testing.T) {
+	sink := stream.NewMemoryMetrics()
+
+	s3fs, err := stream.NewFS("test",
+		stream.WithS3(s3HeadObject),
+		stream.WithS3Upload(s3PutObject),
+		stream.WithMetrics(sink),
+	)
+	it.Then(t).Must(it.Nil(err))
+
+	_, err = s3fs.Stat(file)
+	it.Then(t).Must(it.Nil(err))
+
+	fd, err := s3fs.Create(file, nil)
+	it.Then(t).Must(it.Nil(err))
+	_, err = io.WriteString(fd, content)
+	it.Then(t).Must(it.Nil(err))
+	it.Then(t).Must(it.Nil(fd.Close()))
+
+	s3fs, err = stream.NewFS("test",
+		stream.WithS3(s3HeadObjectNotFound),
+		stream.WithMetrics(sink),
+	)
+	it.Then(t).Must(it.Nil(err))
+
+	_, err = s3fs.Stat(file)
+	it.Then(t).Must(it.True(errors.Is(err, fs.ErrNotExist)))
+
+	head := sink.Get("HeadObject")
+	upload := sink.Get("Upload")
+	it.Then(t).Should(
+		it.Equal(head.Count, 2),
+		it.Equal(head.Errors, 1),
+		it.Equal(upload.Count, 1),
+		it.Equal(upload.Errors, 0),
+		it.Equal(sink.Get("GetObject").Count, 0),
+	)
+}
+
+type userAgentClient struct{ ua string }
+
+func (c *userAgentClient) Do(req *http.Request) (*http.Response, error) {
+	c.ua = req.Header.Get("User-Agent")
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Length": []string{"0"}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestUserAgent(t *testing.T) {
+	client := &userAgentClient{}
+	s3fs, err := stream.NewFS("test",
+		stream.WithConfig(aws.Config{
+			Region:      "eu-west-1",
+			Credentials: aws.AnonymousCredentials{},
+			HTTPClient:  client,
+		}),
+		stream.WithUserAgent("myapp"),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	_, err = s3fs.Stat(file)
+	it.Then(t).Must(it.Nil(err))
+	it.Then(t).Should(
+		it.True(strings.Contains(client.ua, "aws-sdk-go-v2")),
+		it.True(strings.Contains(client.ua, "myapp")),
+	)
+}
+
+// records the last request sent by S3 client
+type recordingClient struct{ req *http.Request }
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Length": []string{"0"}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestNewFromConfig(t *testing.T) {
+	t.Run("NewFromConfig", func(t *testing.T) {
+		client := &recordingClient{}
+		s3fs, err := stream.NewFromConfig[struct{}]("test",
+			aws.Config{
+				Region:      "eu-west-1",
+				Credentials: aws.AnonymousCredentials{},
+				HTTPClient:  client,
+			},
+			stream.WithUserAgent("myapp"),
 		)
 		it.Then(t).Must(it.Nil(err))
 
-		seq, err := s3fs.ReadDir(dir)
-		it.Then(t).Must(
-			it.Nil(err),
-			it.Equal(len(seq), 3),
-		)
+		_, err = s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
 		it.Then(t).Should(
-			it.Equal(seq[0].Name(), "1"),
-			it.Equal(seq[1].Name(), "2"),
-			it.Equal(seq[2].Name(), "3"),
+			it.True(strings.Contains(client.req.URL.Host, "eu-west-1")),
+			it.True(strings.Contains(client.req.Header.Get("User-Agent"), "myapp")),
 		)
 	})
 
-	t.Run("ReadDir/Error", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3ListObjectError),
+	t.Run("Error/Missing", func(t *testing.T) {
+		_, err := stream.NewFromConfig[struct{}]("test",
+			aws.Config{Credentials: aws.AnonymousCredentials{}},
+		)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+}
+
+func TestUploadOptions(t *testing.T) {
+	cfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: aws.AnonymousCredentials{},
+	}
+
+	t.Run("Upload", func(t *testing.T) {
+		s3fs, err := stream.NewFromConfig[struct{}]("test", cfg,
+			stream.WithUploadPartSize(16*1024*1024),
+			stream.WithUploadConcurrency(8),
 		)
 		it.Then(t).Must(it.Nil(err))
 
+		uploader, ok := s3fs.Uploader().(*manager.Uploader)
+		it.Then(t).Must(it.True(ok))
 		it.Then(t).Should(
-			it.Error(s3fs.ReadDir(dir)),
+			it.Equal(uploader.PartSize, 16*1024*1024),
+			it.Equal(uploader.Concurrency, 8),
 		)
 	})
 
-	t.Run("ReadDir/Error/InvalidPath", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+	t.Run("Error/PartSize", func(t *testing.T) {
+		_, err := stream.NewFromConfig[struct{}]("test", cfg,
+			stream.WithUploadPartSize(1024),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
 
-		it.Then(t).Should(
-			it.Error(s3fs.ReadDir("invalid..key/")),
+	t.Run("Error/Concurrency", func(t *testing.T) {
+		_, err := stream.NewFromConfig[struct{}]("test", cfg,
+			stream.WithUploadConcurrency(-1),
 		)
+		it.Then(t).ShouldNot(it.Nil(err))
 	})
+}
 
-	t.Run("Glob", func(t *testing.T) {
+func TestDefaultRegion(t *testing.T) {
+	t.Run("Fallback", func(t *testing.T) {
+		client := &recordingClient{}
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3ListObject),
+			stream.WithConfig(aws.Config{
+				Credentials: aws.AnonymousCredentials{},
+				HTTPClient:  client,
+			}),
+			stream.WithDefaultRegion("eu-central-1"),
 		)
 		it.Then(t).Must(it.Nil(err))
 
-		seq, err := s3fs.Glob(dir)
+		_, err = s3fs.Stat(file)
 		it.Then(t).Must(it.Nil(err))
 		it.Then(t).Should(
-			it.Seq(seq).Equal("1", "2", "3"),
+			it.True(strings.Contains(client.req.URL.Host, "eu-central-1")),
 		)
 	})
 
-	t.Run("GlobWithPattern", func(t *testing.T) {
+	t.Run("Resolved", func(t *testing.T) {
+		client := &recordingClient{}
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3ListObject),
+			stream.WithConfig(aws.Config{
+				Region:      "eu-west-1",
+				Credentials: aws.AnonymousCredentials{},
+				HTTPClient:  client,
+			}),
+			stream.WithDefaultRegion("eu-central-1"),
 		)
 		it.Then(t).Must(it.Nil(err))
 
-		seq, err := s3fs.Glob(dir + "|2")
+		_, err = s3fs.Stat(file)
 		it.Then(t).Must(it.Nil(err))
 		it.Then(t).Should(
-			it.Seq(seq).Equal("2"),
+			it.True(strings.Contains(client.req.URL.Host, "eu-west-1")),
 		)
 	})
 
-	t.Run("GlobWithPattern/Error", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3ListObject),
+	t.Run("Error/Missing", func(t *testing.T) {
+		_, err := stream.NewFS("test",
+			stream.WithConfig(aws.Config{
+				Credentials: aws.AnonymousCredentials{},
+			}),
 		)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+}
+
+func TestRegionForPrefix(t *testing.T) {
+	client := &recordingClient{}
+	s3fs, err := stream.NewFS("test",
+		stream.WithConfig(aws.Config{
+			Region:      "eu-west-1",
+			Credentials: aws.AnonymousCredentials{},
+			HTTPClient:  client,
+		}),
+		stream.WithRegionForPrefix(map[string]string{
+			"us/":      "us-east-2",
+			"us/west/": "us-west-2",
+		}),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	for path, region := range map[string]string{
+		"/us/west/key": "us-west-2",
+		"/us/key":      "us-east-2",
+		"/eu/key":      "eu-west-1",
+	} {
+		_, err = s3fs.Stat(path)
 		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.True(strings.Contains(client.req.URL.Host, region)),
+		)
+	}
+}
 
+func TestRequireValidPath(t *testing.T) {
+	for path, reason := range map[string]string{
+		"":            "empty path",
+		"the/key":     "must start with '/'",
+		"/the//key":   "double slash",
+		"/the/../key": "'..' is not allowed",
+		"/the/key/":   "must not end with '/'",
+	} {
+		err := stream.RequireValidFile("test", path)
 		it.Then(t).Should(
-			it.Error(s3fs.Glob(dir + "|\\")),
+			it.True(errors.Is(err, fs.ErrInvalid)),
+			it.True(strings.Contains(err.Error(), reason)),
 		)
-	})
+	}
 
-	t.Run("WalkDir", func(t *testing.T) {
+	err := stream.RequireValidDir("test", "/the/key")
+	it.Then(t).Should(
+		it.True(errors.Is(err, fs.ErrInvalid)),
+		it.True(strings.Contains(err.Error(), "must end with '/'")),
+	)
+}
+
+func TestExpectedBucketOwner(t *testing.T) {
+	owner := "123456789012"
+
+	t.Run("Stat", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3ListObject),
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey:         file[1:],
+					ExpectBucketOwner: owner,
+					ReturnVal:         s3HeadObject.ReturnVal,
+				},
+			}),
+			stream.WithExpectedBucketOwner(owner),
 		)
 		it.Then(t).Must(it.Nil(err))
 
-		seq := make([]string, 0)
-		err = fs.WalkDir(s3fs, dir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-
-			if d.IsDir() {
-				return nil
-			}
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.Nil(err))
+	})
 
-			it.Then(t).
-				ShouldNot(
-					it.Error(d.Info()),
-				).
-				Should(
-					it.Nil(err),
-					it.Equal(d.Type(), 0),
-				)
+	t.Run("ReadWrite", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey:         file[1:],
+					ExpectBucketOwner: owner,
+					ReturnVal: &s3.GetObjectOutput{
+						Body: io.NopCloser(strings.NewReader(content)),
+					},
+				},
+			}),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey:         file[1:],
+					ExpectVal:         content,
+					ExpectBucketOwner: owner,
+				},
+			}),
+			stream.WithExpectedBucketOwner(owner),
+		)
+		it.Then(t).Must(it.Nil(err))
 
-			seq = append(seq, path)
-			return nil
-		})
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+		_, err = io.WriteString(fd, content)
 		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		buf, err := fs.ReadFile(s3fs, file)
 		it.Then(t).Should(
-			it.Seq(seq).Equal(file+"/1", file+"/2", file+"/3"),
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+	})
+
+	t.Run("Error/Mismatch", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey:         file[1:],
+					ExpectBucketOwner: owner,
+					ReturnVal:         s3HeadObject.ReturnVal,
+				},
+			}),
 		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).ShouldNot(it.Nil(err))
 	})
 }
 
-func TestRemove(t *testing.T) {
-	s3fs, err := stream.NewFS("test",
-		stream.WithS3(s3DeleteObject),
+func TestPathToKey(t *testing.T) {
+	for path, key := range map[string]string{
+		"/":          "",
+		"/a/b":       "a/b",
+		"/a/b/":      "a/b/",
+		"/the/k.txt": "the/k.txt",
+	} {
+		it.Then(t).Should(
+			it.Equal(stream.PathToKey(path), key),
+			it.Equal(stream.KeyToPath(key), path),
+			it.Equal(stream.KeyToPath(stream.PathToKey(path)), path),
+		)
+	}
+
+	it.Then(t).Should(
+		it.Equal(stream.PathToKey("a/b"), "a/b"),
+		it.Equal(stream.KeyToPath("/a/b"), "/a/b"),
 	)
-	it.Then(t).Must(it.Nil(err))
+}
 
-	t.Run("Remove", func(t *testing.T) {
-		err := s3fs.Remove(file)
+func TestIsMultipartETag(t *testing.T) {
+	for etag, multipart := range map[string]bool{
+		"":                                      false,
+		"d41d8cd98f00b204e9800998ecf8427e":      false,
+		`"d41d8cd98f00b204e9800998ecf8427e"`:    false,
+		"d41d8cd98f00b204e9800998ecf8427e-12":   true,
+		`"d41d8cd98f00b204e9800998ecf8427e-12"`: true,
+		"d41d8cd98f00b204e9800998ecf8427e-":     false,
+		"d41d8cd98f00b204e9800998ecf8427e-a":    false,
+	} {
+		it.Then(t).Should(
+			it.Equal(stream.IsMultipartETag(etag), multipart),
+			it.Equal(stream.SystemMetadata{ETag: etag}.ETagIsMD5(), etag != "" && !multipart),
+		)
+	}
+}
+
+func TestRawKeys(t *testing.T) {
+	key := "the//example/../key"
+
+	t.Run("OpenKey", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: key,
+					ReturnVal: &s3.GetObjectOutput{
+						Body: io.NopCloser(strings.NewReader(content)),
+					},
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.OpenKey("/" + key)
 		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
 	})
 
-	t.Run("Remove/Error", func(t *testing.T) {
+	t.Run("OpenKey/Stat", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3DeleteObjectError),
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: key,
+					ReturnVal: s3HeadObject.ReturnVal,
+				},
+			}),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
+		fd, err := s3fs.OpenKey(key)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Remove(file)
-			}),
+			it.Nil(err),
+			it.Equal(fi.Size(), size),
 		)
 	})
 
-	t.Run("Remove/Error/InvalidPath", func(t *testing.T) {
+	t.Run("OpenKey/SeekEnd", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3DeleteObject),
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: key,
+					ReturnVal: s3HeadObject.ReturnVal,
+				},
+			}),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.OpenKey(key)
+		it.Then(t).Must(it.Nil(err))
 
+		pos, err := fd.(io.Seeker).Seek(0, io.SeekEnd)
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Remove("invalid..key/")
-			}),
+			it.Nil(err),
+			it.Equal(pos, size),
 		)
 	})
 
-}
-
-func TestCopy(t *testing.T) {
-	t.Run("Copy", func(t *testing.T) {
+	t.Run("OpenKey/ParallelDownload", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3CopyObject),
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: key},
+				Content: content,
+			}),
+			stream.WithParallelDownload(4, 2),
 		)
 		it.Then(t).Must(it.Nil(err))
 
-		err = s3fs.Copy(file, "s3://test/file")
+		fd, err := s3fs.OpenKey(key)
 		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
 	})
 
-	t.Run("Copy/Error", func(t *testing.T) {
+	t.Run("StatKey", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3CopyObjectError),
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: "/" + key,
+					ReturnVal: s3HeadObject.ReturnVal,
+				},
+			}),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
+		fi, err := s3fs.StatKey("//" + key)
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Copy(file, "s3://test/file")
+			it.Nil(err),
+			it.Equal(fi.Size(), size),
+		)
+	})
+
+	t.Run("RemoveKey", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.DeleteObject{
+				Mock: mocks.Mock[s3.DeleteObjectOutput]{
+					ExpectKey: key,
+				},
 			}),
 		)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Nil(s3fs.RemoveKey(key)),
+		)
 	})
 
-	t.Run("Copy/Error/InvalidPath", func(t *testing.T) {
+	t.Run("Error/InvalidPath", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3CopyObject),
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Stat("/" + key)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+
+		_, err = s3fs.StatKey("/")
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
+func TestReadWrite(t *testing.T) {
+	t.Run("File/Read", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
 		)
 		it.Then(t).Should(it.Nil(err))
 
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Copy("invalid..key/", "s3://test/file")
-			}),
+			it.Nil(err),
+			it.Equal(string(buf), content),
 		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
 	})
 
-	t.Run("Copy/Error/InvalidSchema", func(t *testing.T) {
+	t.Run("Dir/Read", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3CopyObject),
+			stream.WithS3(s3GetObject),
 		)
 		it.Then(t).Should(it.Nil(err))
 
+		fd, err := s3fs.Open(dir)
+		it.Then(t).Must(it.Nil(err))
+
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Copy(file, file)
-			}),
+			it.Error(io.ReadAll(fd)),
 		)
 	})
-}
 
-func TestWait(t *testing.T) {
-	t.Run("Wait", func(t *testing.T) {
+	t.Run("File/Write", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3HeadObject),
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObject),
 		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
 		it.Then(t).Must(it.Nil(err))
 
-		err = s3fs.Wait(file, 5*time.Second)
+		n, err := io.WriteString(fd, content)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(n, len(content)),
+		)
+
+		err = fd.Close()
 		it.Then(t).Must(it.Nil(err))
 	})
 
-	t.Run("Wait/Error/InvalidPath", func(t *testing.T) {
+	t.Run("File/Write/Hashing", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3HeadObject),
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObject),
 		)
 		it.Then(t).Should(it.Nil(err))
 
+		fd, digest, err := s3fs.CreateHashing(file, nil, sha256.New)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Wait("invalid..key/", 5*time.Second)
-			}),
+			it.Nil(err),
+			it.Equal(len(digest()), 0),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+
+		expect := sha256.Sum256([]byte(content))
+		it.Then(t).Should(
+			it.Equiv(digest(), expect[:]),
 		)
 	})
 
-	t.Run("Wait/Error/Timeout", func(t *testing.T) {
+	t.Run("File/Write/Hashing/Error", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3HeadObject),
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObjectError),
 		)
 		it.Then(t).Should(it.Nil(err))
 
+		fd, digest, err := s3fs.CreateHashing(file, nil, sha256.New)
+		it.Then(t).Must(it.Nil(err))
+
+		io.WriteString(fd, content)
 		it.Then(t).Should(
-			it.Fail(func() error {
-				return s3fs.Wait(file, 0*time.Second)
-			}),
+			it.Fail(fd.Close),
+			it.Equal(len(digest()), 0),
 		)
 	})
-}
 
-func TestStat(t *testing.T) {
-	t.Run("Stat", func(t *testing.T) {
+	t.Run("File/Write/Small", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3HeadObject),
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObjectError),
+			stream.WithBufferSmallWrites(1024),
 		)
 		it.Then(t).Should(it.Nil(err))
 
-		fi, err := s3fs.Stat(file)
+		fd, err := s3fs.Create(file, nil)
 		it.Then(t).Must(it.Nil(err))
+
+		n, err := io.WriteString(fd, content)
 		it.Then(t).Should(
-			it.Equal(fi.Name(), file),
-			it.Equal(fi.Size(), size),
-			it.Equiv(fi.ModTime(), modified),
-			it.Equal(fi.IsDir(), false),
-			it.Equal(fi.Mode(), 0),
+			it.Nil(err),
+			it.Equal(n, len(content)),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Write/Small/ContentMD5", func(t *testing.T) {
+		md5sum := md5.Sum([]byte(content))
+		expect := base64.StdEncoding.EncodeToString(md5sum[:])
+
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.PutObject{
+				Mock: s3PutObject.Mock,
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if aws.ToString(req.ContentMD5) != expect {
+						return fmt.Errorf("unexpected ContentMD5 %s", aws.ToString(req.ContentMD5))
+					}
+					return nil
+				},
+			}),
+			stream.WithS3Upload(s3PutObjectError),
+			stream.WithBufferSmallWrites(1024),
 		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
 	})
 
-	t.Run("Stat/Error", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3HeadObjectError),
-		)
-		it.Then(t).Should(it.Nil(err))
+	t.Run("File/Write/ServerSideEncryption", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: s3PutObject.Mock,
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if req.ServerSideEncryption != types.ServerSideEncryptionAwsKms {
+						return fmt.Errorf("unexpected ServerSideEncryption %s", req.ServerSideEncryption)
+					}
+					if aws.ToString(req.SSEKMSKeyId) != "key" {
+						return fmt.Errorf("unexpected SSEKMSKeyId %s", aws.ToString(req.SSEKMSKeyId))
+					}
+					return nil
+				},
+			}),
+			stream.WithServerSideEncryption(types.ServerSideEncryptionAwsKms),
+			stream.WithSSEKMSKeyID("key"),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Write/ServerSideEncryption/Default", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: s3PutObject.Mock,
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if req.ServerSideEncryption != "" || req.SSEKMSKeyId != nil {
+						return fmt.Errorf("unexpected ServerSideEncryption %s", req.ServerSideEncryption)
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Write/Small/Spill", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObjectError),
+			stream.WithS3Upload(s3PutObject),
+			stream.WithBufferSmallWrites(8),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content[:6])
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content[6:])
+		it.Then(t).Must(it.Nil(err))
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Write/Small/Requests", func(t *testing.T) {
+		// object spans two parts of multipart upload but fits the buffer
+		chunk := strings.Repeat("x", 6*1024*1024)
+		write := func(opts ...stream.Option) *mocks.Multipart {
+			client := &mocks.Multipart{}
+			s3fs, err := stream.NewFS("test",
+				append([]stream.Option{
+					stream.WithS3(client),
+					stream.WithS3Upload(manager.NewUploader(client)),
+				}, opts...)...,
+			)
+			it.Then(t).Must(it.Nil(err))
+
+			fd, err := s3fs.Create(file, nil)
+			it.Then(t).Must(it.Nil(err))
+
+			_, err = io.WriteString(fd, chunk)
+			it.Then(t).Must(it.Nil(err))
+			it.Then(t).Must(it.Nil(fd.Close()))
+
+			return client
+		}
+
+		streaming := write()
+		it.Then(t).Should(
+			it.Equal(streaming.Calls("PutObject"), 0),
+			it.Equal(streaming.Calls("CreateMultipartUpload"), 1),
+			it.Equal(streaming.Calls("UploadPart"), 2),
+			it.Equal(streaming.Calls("CompleteMultipartUpload"), 1),
+		)
+
+		buffered := write(stream.WithBufferSmallWrites(8 * 1024 * 1024))
+		it.Then(t).Should(
+			it.Equal(buffered.Calls("PutObject"), 1),
+			it.Equal(buffered.Calls("CreateMultipartUpload"), 0),
+			it.Equal(buffered.Calls("UploadPart"), 0),
+			it.Equal(buffered.Calls("CompleteMultipartUpload"), 0),
+		)
+	})
+
+	t.Run("File/Write/Cancel", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		n, err := io.WriteString(fd, content)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(n, len(content)),
+		)
+
+		err = fd.Cancel()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Read/CloseTwice", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObjectNotFound),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Nil(fd.Close()),
+			it.Nil(fd.Close()),
+		)
+
+		_, err = fd.Read(make([]byte, 1))
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrClosed)),
+		)
+	})
+
+	t.Run("File/Write/CloseTwice", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Nil(fd.Close()),
+			it.Nil(fd.Close()),
+			it.Nil(fd.Cancel()),
+		)
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrClosed)),
+		)
+	})
+
+	t.Run("File/Write/CloseAfterCancel", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Nil(fd.Cancel()),
+			it.Nil(fd.Close()),
+		)
+	})
+
+	t.Run("File/Read/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Open("invalid..key/")),
+		)
+	})
+
+	t.Run("File/Read/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(io.ReadAll(fd)),
+		)
+	})
+
+	t.Run("File/Read/Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObjectNotFound),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+		)
+	})
+
+	t.Run("File/Read/Error/AccessDenied", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObjectAccessDenied),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrAccessDenied)),
+		)
+	})
+
+	t.Run("File/Read/Lines", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body: io.NopCloser(strings.NewReader("a\nb\nc\n")),
+					},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		lines, err := s3fs.OpenLines(file)
+		it.Then(t).Must(it.Nil(err))
+
+		seq := make([]string, 0)
+		for line, err := range lines {
+			it.Then(t).Must(it.Nil(err))
+			seq = append(seq, string(line))
+			if len(seq) == 2 {
+				break
+			}
+		}
+		it.Then(t).Should(
+			it.Seq(seq).Equal("a", "b"),
+		)
+	})
+
+	t.Run("File/Read/Lines/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObjectNotFound),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		lines, err := s3fs.OpenLines(file)
+		it.Then(t).Must(it.Nil(err))
+
+		n := 0
+		for _, err := range lines {
+			n++
+			it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+		}
+		it.Then(t).Should(it.Equal(n, 1))
+	})
+
+	t.Run("File/Read/Error/TooLarge", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content)),
+						ContentLength: aws.Int64(size),
+					},
+				},
+			}),
+			stream.WithMaxObjectSize(size-1),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = fs.ReadFile(s3fs, file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrObjectTooLarge)),
+		)
+	})
+
+	t.Run("File/Read/Decompress", func(t *testing.T) {
+		gz := &bytes.Buffer{}
+		zw := gzip.NewWriter(gz)
+		zw.Write([]byte(content))
+		zw.Close()
+
+		body := &closeTracker{Reader: gz}
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:] + ".gz",
+					ReturnVal: &s3.GetObjectOutput{Body: body},
+				},
+			}),
+			stream.WithDecompressBySuffix(map[string]func(io.Reader) (io.Reader, error){
+				".gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file + ".gz")
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+
+		it.Then(t).Should(
+			it.Nil(fd.Close()),
+			it.True(body.closed),
+		)
+	})
+
+	t.Run("File/Read/Decompress/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:] + ".gz",
+					ReturnVal: &s3.GetObjectOutput{
+						Body: io.NopCloser(strings.NewReader(content)),
+					},
+				},
+			}),
+			stream.WithDecompressBySuffix(map[string]func(io.Reader) (io.Reader, error){
+				".gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = fs.ReadFile(s3fs, file+".gz")
+		it.Then(t).Should(
+			it.True(errors.Is(err, gzip.ErrHeader)),
+		)
+	})
+
+	t.Run("File/Read/Parallel", func(t *testing.T) {
+		sink := stream.NewMemoryMetrics()
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+				Content: content,
+			}),
+			stream.WithParallelDownload(4, 2),
+			stream.WithMetrics(sink),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+			it.Equal(sink.Get("GetObject").Count, 3),
+			it.Equal(sink.Get("HeadObject").Count, 0),
+		)
+
+		fi, err := fd.Stat()
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(fi.Size(), size),
+		)
+
+		it.Then(t).Should(it.Nil(fd.Close()))
+	})
+
+	t.Run("File/Read/Parallel/Small", func(t *testing.T) {
+		sink := stream.NewMemoryMetrics()
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+				Content: content,
+			}),
+			stream.WithParallelDownload(1024, 2),
+			stream.WithMetrics(sink),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		buf, err := s3fs.ReadFile(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+			it.Equal(sink.Get("GetObject").Count, 1),
+		)
+	})
+
+	t.Run("File/Read/Parallel/Cancel", func(t *testing.T) {
+		delay := 5 * time.Second
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					Delay:     &delay,
+				},
+				Content: content,
+			}),
+			stream.WithParallelDownload(4, 2),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		fd, err := s3fs.OpenCtx(ctx, file)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(it.True(errors.Is(err, context.Canceled)))
+	})
+
+	t.Run("File/Read/Parallel/TooLarge", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+				Content: content,
+			}),
+			stream.WithParallelDownload(4, 2),
+			stream.WithMaxObjectSize(8),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.ReadFile(file)
+		it.Then(t).Should(it.True(errors.Is(err, stream.ErrObjectTooLarge)))
+	})
+
+	t.Run("File/Read/Parallel/Checksum", func(t *testing.T) {
+		_, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+			stream.WithParallelDownload(4, 2),
+			stream.WithChecksumValidation(true),
+		)
+		it.Then(t).Should(it.Fail(func() error { return err }))
+	})
+
+	t.Run("File/Read/Range", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content[6:])),
+						ContentLength: aws.Int64(size - 6),
+						ContentRange:  aws.String(fmt.Sprintf("bytes 6-%d/%d", size-1, size)),
+					},
+				},
+				ExpectInput: func(req *s3.GetObjectInput) error {
+					if aws.ToString(req.Range) != "bytes=6-" || aws.ToString(req.IfMatch) != "cafe" {
+						return fmt.Errorf("unexpected range %v, if-match %v", aws.ToString(req.Range), aws.ToString(req.IfMatch))
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.OpenRange(file, 6, stream.IfRange("cafe"))
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content[6:]),
+		)
+
+		fi, err := fd.Stat()
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(fi.Size(), size),
+		)
+	})
+
+	t.Run("File/Read/Range/Ignored", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnErr: &smithy.GenericAPIError{Code: "PreconditionFailed"},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.OpenRange(file, 6, stream.IfRange("cafe"))
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrRangeIgnored)),
+		)
+	})
+
+	t.Run("File/Read/Range/Ignored/FullContent", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content)),
+						ContentLength: aws.Int64(size),
+					},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.OpenRange(file, 6, stream.IfRange("cafe"))
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrRangeIgnored)),
+		)
+	})
+
+	t.Run("File/Read/Range/Error/Offset", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.OpenRange(file, -1)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrInvalid)),
+		)
+	})
+
+	t.Run("File/Read/Seek", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+				},
+				Content: content,
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		seeker := fd.(io.Seeker)
+		buf := make([]byte, 5)
+
+		pos, err := seeker.Seek(6, io.SeekStart)
+		it.Then(t).Must(it.Nil(err))
+		_, err = io.ReadFull(fd, buf)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(pos, 6),
+			it.Equal(string(buf), content[6:11]),
+		)
+
+		pos, err = seeker.Seek(-11, io.SeekCurrent)
+		it.Then(t).Must(it.Nil(err))
+		_, err = io.ReadFull(fd, buf)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(pos, 0),
+			it.Equal(string(buf), content[:5]),
+		)
+
+		pos, err = seeker.Seek(-6, io.SeekEnd)
+		it.Then(t).Must(it.Nil(err))
+		tail, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(pos, 6),
+			it.Equal(string(tail), content[6:]),
+		)
+
+		_, err = seeker.Seek(100, io.SeekStart)
+		it.Then(t).Must(it.Nil(err))
+		_, err = fd.Read(buf)
+		it.Then(t).Should(it.Equal(err, io.EOF))
+	})
+
+	t.Run("File/Read/Reopen", func(t *testing.T) {
+		sink := stream.NewMemoryMetrics()
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+				Content: content,
+			}),
+			stream.WithMetrics(sink),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		head := make([]byte, 5)
+		_, err = io.ReadFull(fd, head)
+		it.Then(t).Must(it.Nil(err))
+
+		err = fd.(stream.Reopener).Reopen()
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(head), content[:5]),
+			it.Equal(string(buf), content),
+			it.Equal(sink.Get("GetObject").Count, 2),
+		)
+
+		fd.Close()
+		it.Then(t).Should(
+			it.Equal(fd.(stream.Reopener).Reopen(), fs.ErrClosed),
+		)
+	})
+
+	t.Run("File/Read/Seek/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = fd.(io.Seeker).Seek(-1, io.SeekStart)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+
+		fd.Close()
+		_, err = fd.(io.Seeker).Seek(0, io.SeekStart)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrClosed)))
+	})
+
+	t.Run("File/Read/ReadAt", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObjectRange{
+				Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+				Content: content,
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		head := make([]byte, 5)
+		_, err = io.ReadFull(fd, head)
+		it.Then(t).Must(it.Nil(err))
+
+		buf := make([]byte, 5)
+		n, err := fd.(io.ReaderAt).ReadAt(buf, 6)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(n, 5),
+			it.Equal(string(buf), content[6:11]),
+		)
+
+		n, err = fd.(io.ReaderAt).ReadAt(buf, 10)
+		it.Then(t).Should(
+			it.Equal(err, io.EOF),
+			it.Equal(n, 2),
+			it.Equal(string(buf[:n]), content[10:]),
+		)
+
+		n, err = fd.(io.ReaderAt).ReadAt(buf, 100)
+		it.Then(t).Should(
+			it.Equal(err, io.EOF),
+			it.Equal(n, 0),
+		)
+
+		tail, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(tail), content[5:]),
+		)
+	})
+
+	t.Run("File/Read/ReadAt/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		_, err = fd.(io.ReaderAt).ReadAt(make([]byte, 5), 0)
+		it.Then(t).ShouldNot(it.Nil(err))
+
+		_, err = fd.(io.ReaderAt).ReadAt(make([]byte, 5), -1)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+
+	t.Run("File/Write/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(s3PutObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(io.WriteString(fd, content)),
+		)
+
+		it.Then(t).Should(
+			it.Fail(fd.Close),
+		)
+	})
+
+	t.Run("File/Write/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Create("invalid..key/", nil)),
+		)
+	})
+
+	t.Run("File/Write/Error/Directory", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Create(dir, nil)),
+		)
+	})
+}
+
+func TestWalk(t *testing.T) {
+	t.Run("ReadDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ReadDir(dir)
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Equal(len(seq), 3),
+		)
+		it.Then(t).Should(
+			it.Equal(seq[0].Name(), "1"),
+			it.Equal(seq[1].Name(), "2"),
+			it.Equal(seq[2].Name(), "3"),
+		)
+	})
+
+	t.Run("ResolveEntry", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ReadDir(dir)
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Equal(len(seq), 3),
+		)
+		it.Then(t).Should(
+			it.Equal(s3fs.ResolveEntry(dir, seq[0]), dir+"1"),
+			it.Equal(s3fs.ResolveEntry(file, seq[1]), dir+"2"),
+		)
+	})
+
+	t.Run("ListModifiedSince", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.ListObject{
+				Mock: mocks.Mock[s3.ListObjectsV2Output]{
+					ExpectKey: dir[1:],
+					ReturnVal: &s3.ListObjectsV2Output{
+						KeyCount: aws.Int32(2),
+						Contents: []types.Object{
+							{Key: aws.String(file[1:] + "/1"), Size: aws.Int64(100), LastModified: aws.Time(modified)},
+							{Key: aws.String(file[1:] + "/2"), Size: aws.Int64(200), LastModified: aws.Time(expires)},
+						},
+					},
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ListModifiedSince(dir, modified)
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Equal(len(seq), 1),
+		)
+		it.Then(t).Should(
+			it.Equal(seq[0].Name(), "2"),
+		)
+	})
+
+	t.Run("ListModifiedSince/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObjectError),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.ListModifiedSince(dir, modified)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+
+	t.Run("Stat/Dir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObjectError),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := s3fs.Stat(dir)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.True(fi.IsDir()),
+			it.Equal(fi.Size(), 0),
+		)
+	})
+
+	t.Run("Stat/Dir/Aggregate", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+			stream.WithDirStatAggregate(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := s3fs.Stat(dir)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.True(fi.IsDir()),
+			it.Equal(fi.Size(), 600),
+			it.Equal(fi.ModTime(), modified),
+		)
+	})
+
+	t.Run("Stat/Dir/Aggregate/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObjectError),
+			stream.WithDirStatAggregate(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Stat(dir)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+
+	t.Run("ReadDir/Paged", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.Open(dir)
+		it.Then(t).Must(it.Nil(err))
+
+		dd, ok := fd.(fs.ReadDirFile)
+		it.Then(t).Must(it.True(ok))
+
+		seq, err := dd.ReadDir(2)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(len(seq), 2),
+			it.Equal(seq[0].Name(), "1"),
+			it.Equal(seq[1].Name(), "2"),
+		)
+
+		seq, err = dd.ReadDir(2)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(len(seq), 1),
+			it.Equal(seq[0].Name(), "3"),
+		)
+
+		_, err = dd.ReadDir(2)
+		it.Then(t).Should(
+			it.True(errors.Is(err, io.EOF)),
+		)
+	})
+
+	t.Run("ReadDir/Throttling", func(t *testing.T) {
+		throttling := func(n int) (*stream.FileSystem[struct{}], *throttlingClient) {
+			client := &throttlingClient{n: n}
+			s3fs, err := stream.NewFS("test",
+				stream.WithConfig(aws.Config{
+					Region:      "eu-west-1",
+					Credentials: aws.AnonymousCredentials{},
+					HTTPClient:  client,
+					Retryer: func() aws.Retryer {
+						return retry.NewStandard(func(o *retry.StandardOptions) {
+							o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+						})
+					},
+				}),
+			)
+			it.Then(t).Must(it.Nil(err))
+			return s3fs, client
+		}
+
+		t.Run("Retry", func(t *testing.T) {
+			s3fs, client := throttling(4)
+
+			seq, err := s3fs.ReadDir(dir)
+			it.Then(t).Must(it.Nil(err))
+			it.Then(t).Should(
+				it.Equal(len(seq), 1),
+				it.Equal(client.calls, 5),
+			)
+		})
+
+		t.Run("Exhausted", func(t *testing.T) {
+			s3fs, client := throttling(100)
+
+			_, err := s3fs.ReadDir(dir)
+			it.Then(t).Should(
+				it.Fail(func() error { return err }),
+				it.Equal(client.calls, 5),
+			)
+		})
+	})
+
+	t.Run("ReadDir/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObjectError),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.ReadDir(dir)),
+		)
+	})
+
+	t.Run("ReadDir/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.ReadDir("invalid..key/")),
+		)
+	})
+
+	t.Run("Glob", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.ListObject{
+				Mock: mocks.Mock[s3.ListObjectsV2Output]{
+					ExpectKey: "the/",
+					ReturnVal: s3ListObject.ReturnVal,
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := fs.Glob(s3fs, "/the/*/key/[12]")
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal(file+"/1", file+"/2"),
+		)
+
+		seq, err = fs.Glob(s3fs, "/the/*")
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal("/the/example"),
+		)
+	})
+
+	t.Run("Glob/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Glob(dir + "[")),
+		)
+	})
+
+	t.Run("GlobRegexp", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.GlobRegexp(dir)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal("1", "2", "3"),
+		)
+	})
+
+	t.Run("GlobRegexpWithPattern", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.GlobRegexp(dir + "|2")
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal("2"),
+		)
+	})
+
+	t.Run("GlobRegexpWithPattern/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.GlobRegexp(dir + "|\\")),
+		)
+	})
+
+	t.Run("WalkDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq := make([]string, 0)
+		err = fs.WalkDir(s3fs, dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			it.Then(t).
+				ShouldNot(
+					it.Error(d.Info()),
+				).
+				Should(
+					it.Nil(err),
+					it.Equal(d.Type(), 0),
+				)
+
+			seq = append(seq, path)
+			return nil
+		})
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal(file+"/1", file+"/2", file+"/3"),
+		)
+	})
+}
+
+func TestWalkLenient(t *testing.T) {
+	t.Run("WalkDir/Error/NoTrailingSlash", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					S3:        s3ListObject,
+					ExpectKey: file[1:],
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = fs.WalkDir(s3fs, file, func(path string, d fs.DirEntry, err error) error { return err })
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+			it.True(strings.Contains(err.Error(), "expected trailing '/'")),
+		)
+	})
+
+	t.Run("Stat/Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+			it.True(!strings.Contains(err.Error(), "trailing '/'")),
+		)
+	})
+
+	t.Run("WalkDir/LenientDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					S3:        s3ListObject,
+					ExpectKey: file[1:],
+				},
+			}),
+			stream.WithLenientDir(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq := make([]string, 0)
+		err = fs.WalkDir(s3fs, file, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() {
+				seq = append(seq, path)
+			}
+			return nil
+		})
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal(file+"/1", file+"/2", file+"/3"),
+		)
+	})
+}
+
+func TestDirectoryMarkers(t *testing.T) {
+	create := func(s3fs *stream.FileSystem[struct{}], path string) error {
+		fd, err := s3fs.Create(path, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fd, content); err != nil {
+			return err
+		}
+		return fd.Close()
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+			stream.WithDirectoryMarkers(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(create(s3fs, "/a/b/c")))
+
+		seq, err := s3fs.ReadDir("/a/")
+		it.Then(t).Should(
+			it.Seq(bucket.Keys()).Equal("a/", "a/b/", "a/b/c"),
+			it.Nil(err),
+			it.Equal(len(seq), 1),
+		)
+	})
+
+	t.Run("Create/NoWrite", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+			stream.WithDirectoryMarkers(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.Create("/a/b/c", nil)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		it.Then(t).Should(
+			it.Equal(len(bucket.Keys()), 0),
+		)
+	})
+
+	t.Run("Create/Disabled", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+		)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(create(s3fs, "/a/b/c")))
+
+		it.Then(t).Should(
+			it.Seq(bucket.Keys()).Equal("a/b/c"),
+		)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+			stream.WithDirectoryMarkers(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(create(s3fs, "/a/b/c")))
+		it.Then(t).Must(it.Nil(s3fs.Remove("/a/b/c")))
+
+		it.Then(t).Should(
+			it.Equal(len(bucket.Keys()), 0),
+		)
+	})
+
+	t.Run("Remove/NotEmpty", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+			stream.WithDirectoryMarkers(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(create(s3fs, "/a/b/c")))
+		it.Then(t).Must(it.Nil(create(s3fs, "/a/x")))
+		it.Then(t).Must(it.Nil(s3fs.Remove("/a/b/c")))
+
+		it.Then(t).Should(
+			it.Seq(bucket.Keys()).Equal("a/", "a/x"),
+		)
+	})
+}
+
+func TestListPrefixes(t *testing.T) {
+	tree := mocks.ListPrefixes{
+		Tree: map[string][]string{
+			"the/":     {"the/a/", "the/b/"},
+			"the/a/":   {"the/a/1/"},
+			"the/a/1/": {},
+			"the/b/":   {},
+		},
+	}
+
+	t.Run("ListPrefixes", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(tree))
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ListPrefixes("/the/")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Seq(seq).Equal("/the/a/", "/the/a/1/", "/the/b/"),
+		)
+	})
+
+	t.Run("MaxDepth", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(tree),
+			stream.WithMaxDepth(1),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ListPrefixes("/the/")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Seq(seq).Equal("/the/a/", "/the/b/"),
+		)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3ListObjectError))
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.ListPrefixes(file + "/")
+		it.Then(t).Should(it.Fail(func() error { return err }))
+	})
+
+	t.Run("Error/NotDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(tree))
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.ListPrefixes(file)
+		it.Then(t).Should(it.Fail(func() error { return err }))
+	})
+}
+
+// tracks Close of the body
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+// throttles first n listing requests, counts requests
+type throttlingClient struct {
+	n     int
+	calls int
+}
+
+func (c *throttlingClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls++
+
+	status, body := http.StatusOK, `<ListBucketResult><KeyCount>1</KeyCount><Contents><Key>the/example/key/a</Key><Size>12</Size></Contents></ListBucketResult>`
+	if c.n > 0 {
+		c.n--
+		status, body = http.StatusServiceUnavailable, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestWriteBatch(t *testing.T) {
+	t.Run("WriteBatch", func(t *testing.T) {
+		sink := stream.NewMemoryMetrics()
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3DeleteObject),
+			stream.WithS3Upload(s3PutObject),
+			stream.WithMetrics(sink),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.WriteBatch([]stream.WriteItem[struct{}]{
+			{Path: file, Body: strings.NewReader(content)},
+			{Path: file, Body: strings.NewReader(content)},
+		})
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(sink.Get("Upload").Count, 2),
+			it.Equal(sink.Get("DeleteObject").Count, 0),
+		)
+	})
+
+	t.Run("WriteBatch/Rollback", func(t *testing.T) {
+		sink := stream.NewMemoryMetrics()
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3DeleteObject),
+			stream.WithS3Upload(s3PutObject),
+			stream.WithMetrics(sink),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.WriteBatch([]stream.WriteItem[struct{}]{
+			{Path: file, Body: strings.NewReader(content)},
+			{Path: "/the/other/key", Body: strings.NewReader(content)},
+			{Path: "/the/skipped/key", Body: strings.NewReader(content)},
+		})
+		it.Then(t).ShouldNot(it.Nil(err))
+
+		var batch *stream.BatchError
+		it.Then(t).Must(it.True(errors.As(err, &batch)))
+		it.Then(t).Should(
+			it.Equal(batch.Succeeded, 0),
+			it.Equal(len(batch.Failed), 3),
+			it.Equal(len(batch.Rollback), 0),
+			it.True(batch.Failed["/the/other/key"] != nil),
+			it.True(errors.Is(batch.Failed[file], stream.ErrBatchAborted)),
+			it.True(errors.Is(batch.Failed["/the/skipped/key"], stream.ErrBatchAborted)),
+			it.True(strings.HasPrefix(err.Error(), "batch failed 3 of 3 items")),
+			it.Equal(sink.Get("Upload").Errors, 1),
+			it.Equal(sink.Get("DeleteObject").Count, 1),
+		)
+	})
+
+	t.Run("WriteBatch/Rollback/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3DeleteObjectError),
+			stream.WithS3Upload(s3PutObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.WriteBatch([]stream.WriteItem[struct{}]{
+			{Path: file, Body: strings.NewReader(content)},
+			{Path: "/the/other/key", Body: strings.NewReader(content)},
+		})
+
+		var batch *stream.BatchError
+		it.Then(t).Must(it.True(errors.As(err, &batch)))
+		it.Then(t).Should(
+			it.Equal(batch.Succeeded, 1),
+			it.Equal(len(batch.Failed), 1),
+			it.Equal(len(batch.Rollback), 1),
+			it.True(batch.Rollback[file] != nil),
+			it.True(strings.HasPrefix(err.Error(), "batch failed 1 of 2 items")),
+			it.True(strings.Contains(err.Error(), "critical failure")),
+		)
+	})
+
+	t.Run("WriteBatch/Error/InvalidPath", func(t *testing.T) {
+		sink := stream.NewMemoryMetrics()
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3DeleteObject),
+			stream.WithS3Upload(s3PutObject),
+			stream.WithMetrics(sink),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.WriteBatch([]stream.WriteItem[struct{}]{
+			{Path: file, Body: strings.NewReader(content)},
+			{Path: dir, Body: strings.NewReader(content)},
+		})
+		var batch *stream.BatchError
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrInvalid)),
+			it.True(errors.As(err, &batch)),
+			it.Equal(sink.Get("Upload").Count, 0),
+		)
+	})
+}
+
+func TestBatchError(t *testing.T) {
+	err := &stream.BatchError{
+		Failed: map[string]error{
+			"/b": errors.New("critical failure"),
+			"/a": fs.ErrNotExist,
+		},
+		Succeeded: 1,
+	}
+
+	it.Then(t).Should(
+		it.True(errors.Is(err, fs.ErrNotExist)),
+		it.Equal(err.Error(), "batch failed 2 of 3 items: /a: file does not exist; /b: critical failure"),
+	)
+
+	t.Run("Rollback", func(t *testing.T) {
+		err := &stream.BatchError{
+			Failed:    map[string]error{"/b": stream.ErrBatchAborted},
+			Succeeded: 1,
+			Rollback:  map[string]error{"/a": fs.ErrPermission},
+		}
+
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrPermission)),
+			it.Equal(err.Error(), "batch failed 1 of 2 items: /b: batch aborted; rollback /a: permission denied"),
+		)
+	})
+}
+
+func TestRemove(t *testing.T) {
+	s3fs, err := stream.NewFS("test",
+		stream.WithS3(s3DeleteObject),
+	)
+	it.Then(t).Must(it.Nil(err))
+
+	t.Run("Remove", func(t *testing.T) {
+		err := s3fs.Remove(file)
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("Remove/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3DeleteObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Remove(file)
+			}),
+		)
+	})
+
+	t.Run("Remove/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3DeleteObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Remove("invalid..key/")
+			}),
+		)
+	})
+
+}
+
+func TestRemoveAll(t *testing.T) {
+	t.Run("RemoveAll", func(t *testing.T) {
+		calls := [][]string{}
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.DeleteObjects{Calls: &calls}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		paths := make([]string, 2500)
+		for i := range paths {
+			paths[i] = fmt.Sprintf("/the/example/%d", i)
+		}
+
+		err = s3fs.RemoveAll(paths)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(calls), 3),
+			it.Equal(len(calls[0]), 1000),
+			it.Equal(len(calls[2]), 500),
+			it.Equal(calls[2][499], "the/example/2499"),
+		)
+	})
+
+	t.Run("RemoveAll/Markers", func(t *testing.T) {
+		bucket := mocks.NewBucket(map[string]string{
+			"a/": "", "a/b/": "", "a/b/c": content, "a/b/d": content, "a/x": content,
+		})
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(bucket),
+			stream.WithDirectoryMarkers(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveAll([]string{"/a/b/c", "/a/b/d"})
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Seq(bucket.Keys()).Equal("a/", "a/x"),
+		)
+	})
+
+	t.Run("RemoveAll/Error/Markers", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.DeleteObjects{
+				Mock: mocks.Mock[s3.DeleteObjectsOutput]{
+					S3: mocks.ListObject{
+						Mock: mocks.Mock[s3.ListObjectsV2Output]{
+							ExpectKey: "a/",
+							ReturnErr: errors.New("critical failure"),
+						},
+					},
+				},
+			}),
+			stream.WithDirectoryMarkers(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveAll([]string{"/a/b", "/a/c"})
+
+		var batch *stream.BatchError
+		it.Then(t).Must(it.True(errors.As(err, &batch)))
+		it.Then(t).Should(
+			it.Equal(batch.Succeeded, 1),
+			it.Equal(len(batch.Failed), 1),
+			it.True(batch.Failed["/a/b"] != nil),
+			it.True(strings.HasPrefix(err.Error(), "batch failed 1 of 2 items")),
+		)
+	})
+
+	t.Run("RemoveAll/Error/Partial", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.DeleteObjects{
+				Failed: map[string]string{file[1:]: "AccessDenied"},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveAll([]string{file, "/the/other/key"})
+
+		var batch *stream.BatchError
+		var perr *fs.PathError
+		it.Then(t).Must(it.True(errors.As(err, &batch)))
+		it.Then(t).Should(
+			it.Equal(batch.Succeeded, 1),
+			it.Equal(len(batch.Failed), 1),
+			it.True(errors.As(err, &perr)),
+			it.Equal(perr.Path, file),
+			it.Equal(len(batch.Unwrap()), 1),
+		)
+	})
+
+	t.Run("RemoveAll/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.DeleteObjects{
+				Mock: mocks.Mock[s3.DeleteObjectsOutput]{
+					ReturnErr: errors.New("critical failure"),
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveAll([]string{file, "/the/other/key"})
+
+		var batch *stream.BatchError
+		it.Then(t).Must(it.True(errors.As(err, &batch)))
+		it.Then(t).Should(
+			it.Equal(batch.Succeeded, 0),
+			it.Equal(len(batch.Failed), 2),
+		)
+	})
+
+	t.Run("RemoveAll/Error/InvalidPath", func(t *testing.T) {
+		calls := [][]string{}
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.DeleteObjects{Calls: &calls}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveAll([]string{file, dir})
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrInvalid)),
+			it.Equal(len(calls), 0),
+		)
+	})
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("Copy", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3CopyObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Copy(file, "s3://test/file")
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("Copy/ServerSideEncryption", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock:        s3CopyObject.Mock,
+				ExpectInput: expectCopySSE,
+			}),
+			stream.WithServerSideEncryption(types.ServerSideEncryptionAwsKms),
+			stream.WithSSEKMSKeyID("key"),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Copy(file, "s3://test/file")
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("Copy/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3CopyObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Copy(file, "s3://test/file")
+			}),
+		)
+	})
+
+	t.Run("Copy/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3CopyObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Copy("invalid..key/", "s3://test/file")
+			}),
+		)
+	})
+
+	t.Run("CopyWithProgress", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content)),
+						ContentLength: aws.Int64(size),
+					},
+				},
+			}),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: "the/copy",
+					ExpectVal: content,
+				},
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if aws.ToString(req.Bucket) != "other" || req.Metadata["author"] != "fogfish" {
+						return fmt.Errorf("unexpected request %+v", req)
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		var copied, total int64
+		err = s3fs.CopyWithProgress(file, "s3://other/the/copy", func(c, t int64) {
+			copied, total = c, t
+		})
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(copied, size),
+			it.Equal(total, size),
+		)
+	})
+
+	t.Run("CopyWithProgress/Error/InvalidTarget", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.CopyWithProgress(file, "s3://other", nil)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+
+	t.Run("CopyWithProgress/Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.CopyWithProgress(file, "/the/copy", nil)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Copy/Error/InvalidSchema", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3CopyObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Copy(file, file)
+			}),
+		)
+	})
+}
+
+// asserts server-side encryption of copy, configured as aws:kms with "key"
+func expectCopySSE(req *s3.CopyObjectInput) error {
+	if req.ServerSideEncryption != types.ServerSideEncryptionAwsKms {
+		return fmt.Errorf("unexpected ServerSideEncryption %s", req.ServerSideEncryption)
+	}
+	if aws.ToString(req.SSEKMSKeyId) != "key" {
+		return fmt.Errorf("unexpected SSEKMSKeyId %s", aws.ToString(req.SSEKMSKeyId))
+	}
+	return nil
+}
+
+func TestRename(t *testing.T) {
+	t.Run("Rename", func(t *testing.T) {
+		bucket := mocks.NewBucket(map[string]string{file[1:]: content})
+		s3fs, err := stream.NewFS("test", stream.WithS3(bucket))
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Rename(file, "/the/other/key")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Seq(bucket.Keys()).Equal("the/other/key"),
+			it.Equal(bucket.Objects["the/other/key"], content),
+		)
+	})
+
+	t.Run("Rename/ServerSideEncryption", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock: mocks.Mock[s3.CopyObjectOutput]{
+					S3:        s3DeleteObject,
+					ExpectKey: "the/other/key",
+				},
+				ExpectInput: expectCopySSE,
+			}),
+			stream.WithServerSideEncryption(types.ServerSideEncryptionAwsKms),
+			stream.WithSSEKMSKeyID("key"),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Rename(file, "/the/other/key")
+		it.Then(t).Should(it.Nil(err))
+	})
+
+	t.Run("Error/NotFound", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test", stream.WithS3(bucket))
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Rename(file, "/the/other/key")
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Error/Remove", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock: mocks.Mock[s3.CopyObjectOutput]{
+					S3:        s3DeleteObjectError,
+					ExpectKey: "the/other/key",
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Rename(file, "/the/other/key")
+		var e *fs.PathError
+		it.Then(t).Should(it.True(errors.As(err, &e)))
+	})
+
+	t.Run("Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(mocks.NewBucket(nil)))
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Rename(file, dir)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
+func TestUpdateMeta(t *testing.T) {
+	t.Run("UpdateMeta", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock: mocks.Mock[s3.CopyObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnVal: &s3.CopyObjectOutput{},
+				},
+				ExpectInput: func(req *s3.CopyObjectInput) error {
+					if aws.ToString(req.CopySource) != "test"+file ||
+						req.MetadataDirective != types.MetadataDirectiveReplace ||
+						aws.ToString(req.ContentType) != "text/plain" ||
+						req.Metadata["author"] != "fogfish" {
+						return fmt.Errorf("unexpected request %+v", req)
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.UpdateMeta(file, &note)
+		it.Then(t).Should(it.Nil(err))
+	})
+
+	t.Run("UpdateMeta/ServerSideEncryption", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock: mocks.Mock[s3.CopyObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnVal: &s3.CopyObjectOutput{},
+				},
+				ExpectInput: expectCopySSE,
+			}),
+			stream.WithServerSideEncryption(types.ServerSideEncryptionAwsKms),
+			stream.WithSSEKMSKeyID("key"),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.UpdateMeta(file, &note)
+		it.Then(t).Should(it.Nil(err))
+	})
+
+	t.Run("UpdateMeta/Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock: mocks.Mock[s3.CopyObjectOutput]{
+					S3:        s3HeadObjectNotFound,
+					ExpectKey: file[1:],
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.UpdateMeta(file, &note)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("UpdateMeta/Error", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.CopyObject{
+				Mock: mocks.Mock[s3.CopyObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnErr: errors.New("critical failure"),
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.UpdateMeta(file, &note)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+
+	t.Run("UpdateMeta/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(s3CopyObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.UpdateMeta(dir, &note)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
+func TestIncompleteUploads(t *testing.T) {
+	s3ListMultipartUploads := mocks.ListMultipartUploads{
+		Mock: mocks.Mock[s3.ListMultipartUploadsOutput]{
+			S3: mocks.AbortMultipartUpload{
+				Mock: mocks.Mock[s3.AbortMultipartUploadOutput]{
+					ExpectKey: file[1:],
+				},
+			},
+			ExpectKey: "",
+			ReturnVal: &s3.ListMultipartUploadsOutput{
+				Uploads: []types.MultipartUpload{
+					{Key: aws.String(file[1:]), UploadId: aws.String("1"), Initiated: aws.Time(modified)},
+					{Key: aws.String(file[1:]), UploadId: aws.String("2"), Initiated: aws.Time(time.Now())},
+				},
+			},
+		},
+	}
+
+	t.Run("List", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListMultipartUploads),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ListIncompleteUploads("/")
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(len(seq), 2),
+			it.Equal(seq[0].Path, file),
+			it.Equal(seq[0].UploadID, "1"),
+			it.Equiv(seq[0].Initiated, modified),
+		)
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3ListMultipartUploads),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		n, err := s3fs.AbortIncompleteUploads(24 * time.Hour)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(n, 1),
+		)
+	})
+}
+
+func TestWait(t *testing.T) {
+	t.Run("Wait", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Wait(file, 5*time.Second)
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("Wait/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Wait("invalid..key/", 5*time.Second)
+			}),
+		)
+	})
+
+	t.Run("Wait/Exists", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		t0 := time.Now()
+		err = s3fs.Wait(file, 0)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Less(time.Since(t0), 100*time.Millisecond),
+		)
+	})
+
+	t.Run("Wait/Waiter", func(t *testing.T) {
+		delay := 10 * time.Millisecond
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+			stream.WithS3Waiter(mocks.Waiter{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: file[1:],
+					Delay:     &delay,
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Wait(file, time.Second)
+		it.Then(t).Should(it.Nil(err))
+	})
+
+	t.Run("Wait/Waiter/Timeout", func(t *testing.T) {
+		delay := 10 * time.Second
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+			stream.WithS3Waiter(mocks.Waiter{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: file[1:],
+					Delay:     &delay,
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.Wait(file, time.Second)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+
+	t.Run("Wait/Error/Timeout", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Fail(func() error {
+				return s3fs.Wait(file, 0*time.Second)
+			}),
+		)
+	})
+}
+
+func TestStat(t *testing.T) {
+	t.Run("Stat", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(fi.Name(), file),
+			it.Equal(fi.Size(), size),
+			it.Equiv(fi.ModTime(), modified),
+			it.Equal(fi.IsDir(), false),
+			it.Equal(fi.Mode(), 0),
+		)
+	})
+
+	t.Run("Stat/Normalized", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithPathNormalization(true),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		for _, path := range []string{file, file[1:], "//the//example/key"} {
+			fi, err := s3fs.Stat(path)
+			it.Then(t).Must(it.Nil(err))
+			it.Then(t).Should(
+				it.Equal(fi.Name(), file),
+			)
+		}
+	})
+
+	t.Run("Stat/Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Stat(file)),
+		)
+	})
+
+	t.Run("Stat/Error/AccessDenied", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectAccessDenied),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+
+		var apiErr smithy.APIError
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrAccessDenied)),
+			it.True(errors.As(err, &apiErr)),
+		)
+	})
+
+	t.Run("Stat/Error/NotFound/Status", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnErr: &smithyhttp.ResponseError{
+						Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 404}},
+						Err:      errors.New("not found"),
+					},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+		)
+	})
+
+	t.Run("Stat/Error/NotFound/Detector", func(t *testing.T) {
+		errGone := errors.New("gone")
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnErr: errGone,
+				},
+			}),
+			stream.WithNotFoundDetector(func(err error) bool { return errors.Is(err, errGone) }),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+		)
+	})
+
+	t.Run("Read/Error/NotFound/Detector", func(t *testing.T) {
+		errGone := errors.New("gone")
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnErr: errGone,
+				},
+			}),
+			stream.WithNotFoundDetector(func(err error) bool { return errors.Is(err, errGone) }),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.ReadFile(file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+		)
+	})
+
+	t.Run("Stat/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Stat("invalid..key/")),
+		)
+	})
+
+	t.Run("Stat/Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+		)
+	})
+
+	t.Run("RawHead", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		head, err := s3fs.RawHead(file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(aws.ToString(head.ETag), "cafe"),
+			it.Equal(head.Metadata["author"], "fogfish"),
+		)
+	})
+
+	t.Run("RawHead/Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObjectNotFound),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.RawHead(file)
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+		)
+	})
+
+	t.Run("File/Stat", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(fi.Name(), file),
+			it.Equal(fi.Size(), size),
+			it.Equiv(fi.ModTime(), modified),
+			it.Equal(fi.IsDir(), false),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Stat.Read", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(fi.Name(), file),
+			it.Equal(fi.Size(), size),
+			it.Equiv(fi.ModTime(), modified),
+			it.Equal(fi.IsDir(), false),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("Dir/Stat", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(dir)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(fi.Name(), dir),
+			it.Equal(fi.Size(), 0),
+			it.Equiv(fi.ModTime(), time.Time{}),
+			it.Equal(fi.IsDir(), true),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+}
+
+type Note struct {
+	stream.SystemMetadata
+	Author  string
+	Chapter string
+}
+
+func TestMetadata(t *testing.T) {
+	t.Run("Stat", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).ShouldNot(
+			it.Nil(fi.Sys()),
+		).
+			Should(
+				it.Equiv(meta, &note),
+			)
+	})
+
+	t.Run("File/Stat", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equiv(meta, &note),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Stat/HeadObject", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:               io.NopCloser(strings.NewReader(content)),
+						ContentLength:      aws.Int64(size),
+						ContentType:        aws.String("text/plain"),
+						LastModified:       aws.Time(modified),
+						CacheControl:       aws.String("no-cache"),
+						ContentDisposition: aws.String("attachment; filename=key.txt"),
+						ContentEncoding:    aws.String("identity"),
+						ContentLanguage:    aws.String("en"),
+						Expires:            aws.Time(expires),
+						ETag:               aws.String("cafe"),
+						StorageClass:       types.StorageClassGlacier,
+						Metadata: map[string]string{
+							"author":  "fogfish",
+							"chapter": "streaming",
+						},
+					},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		head, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Must(it.Nil(err))
+
+		get, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(head.Name(), get.Name()),
+			it.Equal(head.Size(), get.Size()),
+			it.Equiv(head.ModTime(), get.ModTime()),
+			it.Equiv(s3fs.StatSys(head), s3fs.StatSys(get)),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Create", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: file[1:],
+					ExpectVal: content,
+				},
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if aws.ToString(req.ContentDisposition) != note.ContentDisposition {
+						return fmt.Errorf("unexpected content disposition %v", aws.ToString(req.ContentDisposition))
+					}
+					if aws.ToString(req.ContentType) != note.ContentType {
+						return fmt.Errorf("unexpected content type %v", aws.ToString(req.ContentType))
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &note)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Stat.Read", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(s3GetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equiv(meta, &note),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+}
+
+type Provenance struct {
+	Source string
+	Owner  string `hseq:"owner"`
+}
+
+type Record struct {
+	Author     string
+	Provenance Provenance
+}
+
+func TestMetadataNested(t *testing.T) {
+	record := Record{
+		Author:     "fogfish",
+		Provenance: Provenance{Source: "s3", Owner: "team"},
+	}
+	meta := map[string]string{
+		"author":            "fogfish",
+		"provenance-source": "s3",
+		"provenance-owner":  "team",
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		s3fs, err := stream.New[Record]("test",
+			stream.WithS3(mocks.HeadObject{
+				Mock: mocks.Mock[s3.HeadObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.HeadObjectOutput{Metadata: meta},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equiv(s3fs.StatSys(fi), &record),
+		)
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		s3fs, err := stream.New[Record]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: file[1:],
+					ExpectVal: content,
+				},
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if !reflect.DeepEqual(req.Metadata, meta) {
+						return fmt.Errorf("unexpected metadata %v", req.Metadata)
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &record)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+	})
+}
+
+func TestMetadataStorageClass(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: file[1:],
+					ExpectVal: content,
+				},
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if req.StorageClass != types.StorageClassStandardIa {
+						return fmt.Errorf("unexpected StorageClass %s", req.StorageClass)
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &Note{SystemMetadata: stream.SystemMetadata{StorageClass: "STANDARD_IA"}})
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &Note{SystemMetadata: stream.SystemMetadata{StorageClass: "STANDARD_IA"}})
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(s3fs.StatSys(fi).StorageClass, "STANDARD_IA"),
+		)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &Note{})
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		it.Then(t).Should(
+			it.Equal(len(bucket.Classes), 0),
+		)
+	})
+}
+
+type Sized struct {
+	ContentType   string
+	ContentLength int64
+}
+
+func TestMetadataContentLength(t *testing.T) {
+	t.Run("Stat", func(t *testing.T) {
+		s3fs, err := stream.New[Sized]("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equiv(s3fs.StatSys(fi), &Sized{ContentType: "text/plain", ContentLength: size}),
+		)
+	})
+
+	t.Run("File/Read", func(t *testing.T) {
+		s3fs, err := stream.New[Sized]("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content)),
+						ContentLength: aws.Int64(size),
+						ContentType:   aws.String("text/plain"),
+					},
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equiv(s3fs.StatSys(fi), &Sized{ContentType: "text/plain", ContentLength: size}),
+			it.Nil(fd.Close()),
+		)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		s3fs, err := stream.New[Sized]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: file[1:],
+					ExpectVal: content,
+				},
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if req.ContentLength != nil {
+						return fmt.Errorf("unexpected ContentLength %d", aws.ToInt64(req.ContentLength))
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &Sized{ContentLength: 1024})
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+	})
+}
+
+type Labeled struct {
+	Author      string
+	Environment string `tag:"environment"`
+	Team        string `tag:"team"`
+}
+
+func TestMetadataTagging(t *testing.T) {
+	labeled := Labeled{Author: "fogfish", Environment: "prod", Team: "data & ml"}
+
+	t.Run("Encode", func(t *testing.T) {
+		s3fs, err := stream.New[Labeled]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: file[1:],
+					ExpectVal: content,
+				},
+				ExpectInput: func(req *s3.PutObjectInput) error {
+					if tagging := aws.ToString(req.Tagging); tagging != "environment=prod&team=data+%26+ml" {
+						return fmt.Errorf("unexpected tagging %s", tagging)
+					}
+					if !reflect.DeepEqual(req.Metadata, map[string]string{"author": "fogfish"}) {
+						return fmt.Errorf("unexpected metadata %v", req.Metadata)
+					}
+					return nil
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &labeled)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.New[Labeled]("test",
+			stream.WithS3(bucket),
+			stream.WithS3Upload(bucket),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, &labeled)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(s3fs.StatSys(fi).Environment, labeled.Environment),
+			it.Equal(s3fs.StatSys(fi).Team, labeled.Team),
+		)
+
+		r, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+		_, err = io.ReadAll(r)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err = r.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(s3fs.StatSys(fi).Environment, labeled.Environment),
+			it.Equal(s3fs.StatSys(fi).Team, labeled.Team),
+			it.Nil(r.Close()),
+		)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		s3fs, err := stream.New[Labeled]("test",
+			stream.WithS3(mocks.GetObjectTagging{
+				Mock: mocks.Mock[s3.GetObjectTaggingOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnErr: errors.New("access denied"),
+				},
+			}),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		var e *fs.PathError
+		it.Then(t).Should(
+			it.True(errors.As(err, &e)),
+			it.Equal(e.Op, "stat"),
+		)
+	})
+
+	t.Run("NoTags", func(t *testing.T) {
+		s3fs, err := stream.New[Record]("test", stream.WithS3(s3HeadObject))
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.Nil(err))
+	})
+}
+
+func TestTail(t *testing.T) {
+	api := mocks.GetObjectRange{
+		Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+		Content: content,
+	}
+
+	t.Run("Tail", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(api))
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := s3fs.Tail(file, 5)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content[len(content)-5:]),
+		)
+	})
+
+	t.Run("Tail/Short", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(api))
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := s3fs.Tail(file, 1024)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+	})
+
+	t.Run("Tail/Empty", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnErr: &smithy.GenericAPIError{Code: "InvalidRange"},
+				},
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := s3fs.Tail(file, 5)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(buf), 0),
+		)
+	})
+
+	t.Run("Error/NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3GetObjectNotFound))
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Tail(file, 5)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Error/Length", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(api))
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Tail(file, 0)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
+func TestOpenWithInfo(t *testing.T) {
+	expectIfNoneMatch := func(req *s3.GetObjectInput) error {
+		if aws.ToString(req.IfNoneMatch) != "cafe" {
+			return fmt.Errorf("unexpected if-none-match %v", aws.ToString(req.IfNoneMatch))
+		}
+		return nil
+	}
+
+	t.Run("NotModified", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnErr: &smithy.GenericAPIError{Code: "NotModified"},
+				},
+				ExpectInput: expectIfNoneMatch,
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		known, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.OpenWithInfo(file, known)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(fi.Size(), size),
+			it.Equal(s3fs.StatSys(fi).Author, note.Author),
+			it.Equal(s3fs.StatSys(fi).ETag, note.ETag),
+		)
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(it.True(errors.Is(err, stream.ErrNotModified)))
+		it.Then(t).Should(it.Nil(fd.Close()))
+	})
+
+	t.Run("Modified", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test",
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					S3:        s3HeadObject,
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content)),
+						ContentLength: aws.Int64(size),
+						ETag:          aws.String("beef"),
+					},
+				},
+				ExpectInput: expectIfNoneMatch,
+			}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		known, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.OpenWithInfo(file, known)
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(s3fs.StatSys(fi).ETag, "beef"),
+		)
+	})
+}
+
+func TestPreSignPost(t *testing.T) {
+	config := aws.Config{
+		Region: "eu-west-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	}
+
+	t.Run("PreSignPost", func(t *testing.T) {
+		s3fs, err := stream.New[Note]("test", stream.WithConfig(config))
+		it.Then(t).Must(it.Nil(err))
+
+		post, err := s3fs.PreSignPost(file, &Note{Author: "fogfish"}, time.Minute, 1024)
+		it.Then(t).Must(it.Nil(err))
+
+		policy, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Equal(post.URL, "https://test.s3.eu-west-1.amazonaws.com/"),
+			it.Equal(post.Fields["key"], file[1:]),
+			it.Equal(post.Fields["x-amz-meta-author"], "fogfish"),
+			it.True(strings.HasPrefix(post.Fields["x-amz-credential"], "AKID/")),
+			it.Equal(len(post.Fields["x-amz-signature"]), 64),
+			it.True(strings.Contains(string(policy), `["content-length-range",0,1024]`)),
+			it.True(strings.Contains(string(policy), `{"key":"`+file[1:]+`"}`)),
+		)
+	})
+
+	t.Run("PreSignPost/Tagging", func(t *testing.T) {
+		s3fs, err := stream.New[Labeled]("test", stream.WithConfig(config))
+		it.Then(t).Must(it.Nil(err))
+
+		post, err := s3fs.PreSignPost(file, &Labeled{Environment: "prod", Team: "data & ml"}, time.Minute, 1024)
+		it.Then(t).Must(it.Nil(err))
+
+		tagging := `<Tagging><TagSet><Tag><Key>environment</Key><Value>prod</Value></Tag><Tag><Key>team</Key><Value>data &amp; ml</Value></Tag></TagSet></Tagging>`
+		policy, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+		it.Then(t).Must(it.Nil(err))
+
+		cond, err := json.Marshal(map[string]string{"tagging": tagging})
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Equal(post.Fields["tagging"], tagging),
+			it.True(strings.Contains(string(policy), string(cond))),
+		)
+	})
+
+	t.Run("PreSignPost/RegionForPrefix", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithConfig(config),
+			stream.WithRegionForPrefix(map[string]string{"the/example/": "us-east-2"}),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		post, err := s3fs.PreSignPost(file, nil, time.Minute, 1024)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Equal(post.URL, "https://test.s3.us-east-2.amazonaws.com/"),
+			it.True(strings.HasSuffix(post.Fields["x-amz-credential"], "/us-east-2/s3/aws4_request")),
+		)
+	})
+
+	t.Run("PreSignPost/Error/ExpectedBucketOwner", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithConfig(config),
+			stream.WithExpectedBucketOwner("123456789012"),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.PreSignPost(file, nil, time.Minute, 1024)
+		it.Then(t).Should(it.True(errors.Is(err, errors.ErrUnsupported)))
+	})
+
+	t.Run("PreSignPost/Error/NoConfig", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.PreSignPost(file, nil, time.Minute, 1024)
+		it.Then(t).Should(it.True(errors.Is(err, errors.ErrUnsupported)))
+	})
+
+	t.Run("PreSignPost/Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3HeadObject),
+		)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.PreSignPost(dir, nil, time.Minute, 1024)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
+func TestDefaultMetadata(t *testing.T) {
+	s3fs, err := stream.New[Note]("test",
+		stream.WithS3(s3PutObject),
+		stream.WithS3Upload(mocks.PutObject{
+			Mock: mocks.Mock[manager.UploadOutput]{
+				ExpectKey: file[1:],
+				ExpectVal: content,
+			},
+			ExpectInput: func(req *s3.PutObjectInput) error {
+				if req.Metadata["author"] != "fogfish" || req.Metadata["env"] != "prod" {
+					return fmt.Errorf("unexpected metadata %v", req.Metadata)
+				}
+				return nil
+			},
+		}),
+		stream.WithDefaultMetadata(map[string]string{
+			"author": "default",
+			"env":    "prod",
+		}),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	fd, err := s3fs.Create(file, &Note{Author: "fogfish"})
+	it.Then(t).Must(it.Nil(err))
+
+	_, err = io.WriteString(fd, content)
+	it.Then(t).Must(it.Nil(err))
+
+	err = fd.Close()
+	it.Then(t).Must(it.Nil(err))
+}
+
+func TestPutObjectHook(t *testing.T) {
+	s3fs, err := stream.NewFS("test",
+		stream.WithS3(s3PutObject),
+		stream.WithS3Upload(mocks.PutObject{
+			Mock: mocks.Mock[manager.UploadOutput]{
+				ExpectKey: file[1:],
+				ExpectVal: content,
+			},
+			ExpectInput: func(req *s3.PutObjectInput) error {
+				if aws.ToString(req.WebsiteRedirectLocation) != "/redirect" {
+					return fmt.Errorf("unexpected redirect %v", aws.ToString(req.WebsiteRedirectLocation))
+				}
+				return nil
+			},
+		}),
+		stream.WithPutObjectHook(func(req *s3.PutObjectInput) {
+			req.WebsiteRedirectLocation = aws.String("/redirect")
+		}),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	fd, err := s3fs.Create(file, nil)
+	it.Then(t).Must(it.Nil(err))
+
+	_, err = io.WriteString(fd, content)
+	it.Then(t).Must(it.Nil(err))
+
+	err = fd.Close()
+	it.Then(t).Must(it.Nil(err))
+}
+
+type requestID struct{}
+
+func TestContextMetadata(t *testing.T) {
+	s3fs, err := stream.NewFS("test",
+		stream.WithS3(s3PutObject),
+		stream.WithS3Upload(mocks.PutObject{
+			Mock: mocks.Mock[manager.UploadOutput]{
+				ExpectKey: file[1:],
+				ExpectVal: content,
+			},
+			ExpectInput: func(req *s3.PutObjectInput) error {
+				if req.Metadata["request-id"] != "req-1" || req.Metadata["trace"] != "" {
+					return fmt.Errorf("unexpected metadata %v", req.Metadata)
+				}
+				return nil
+			},
+		}),
+		stream.WithContextMetadataKey("request-id", requestID{}),
+		stream.WithContextMetadataKey("trace", "trace"),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	ctx := context.WithValue(context.Background(), requestID{}, "req-1")
+	fd, err := s3fs.CreateCtx(ctx, file, nil)
+	it.Then(t).Must(it.Nil(err))
+
+	_, err = io.WriteString(fd, content)
+	it.Then(t).Must(it.Nil(err))
+
+	err = fd.Close()
+	it.Then(t).Must(it.Nil(err))
+}
+
+func TestOpenArchive(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("the/member")
+	it.Then(t).Must(it.Nil(err))
+	_, err = io.WriteString(w, content)
+	it.Then(t).Must(it.Nil(err))
+	it.Then(t).Must(it.Nil(zw.Close()))
+
+	// archive is read through io.ReaderAt, using ranged requests
+	s3fs, err := stream.NewFS("test",
+		stream.WithS3(mocks.GetObjectRange{
+			Mock: mocks.Mock[s3.GetObjectOutput]{
+				S3: mocks.HeadObject{
+					Mock: mocks.Mock[s3.HeadObjectOutput]{
+						ExpectKey: file[1:],
+						ReturnVal: &s3.HeadObjectOutput{
+							ContentLength: aws.Int64(int64(buf.Len())),
+						},
+					},
+				},
+				ExpectKey: file[1:],
+			},
+			Content: buf.String(),
+		}),
+	)
+	it.Then(t).Should(it.Nil(err))
+
+	zfs, err := stream.OpenArchive(s3fs, file)
+	it.Then(t).Must(it.Nil(err))
+
+	val, err := fs.ReadFile(zfs, "the/member")
+	it.Then(t).Should(
+		it.Nil(err),
+		it.Equal(string(val), content),
+	)
+}
+
+func TestPreSign(t *testing.T) {
+	t.Run("PreSignUrl", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithS3Signer(s3PresignGetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equal(meta.PreSignedUrl, presignedUrl),
+		)
+	})
+
+	t.Run("PreSignUrl/Disabled", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithS3Signer(s3PresignGetObjectError),
+			stream.WithAutoPreSign(false),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equal(meta.PreSignedUrl, ""),
+		)
+	})
+
+	t.Run("PreSignGetURL", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithS3Signer(s3PresignGetObject),
+			stream.WithAutoPreSign(false),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		url, err := s3fs.PreSignGetURL(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(url, presignedUrl),
+		)
+	})
+
+	t.Run("PreSignGetURL/Error", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithS3Signer(s3PresignGetObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.PreSignGetURL(file)
+		it.Then(t).Should(it.Fail(func() error { return err }))
+	})
+
+	t.Run("File/Read/PreSignUrl", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithS3Signer(s3PresignGetObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equal(meta.PreSignedUrl, presignedUrl),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Read/PreSignUrl/Error", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3HeadObject),
+			stream.WithS3Signer(s3PresignGetObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equal(meta.PreSignedUrl, ""),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Write/PreSignUrl", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Signer(s3PresignPutObject),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equal(meta.PreSignedUrl, presignedUrl),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+	t.Run("File/Write/PreSignUrl/Error", func(t *testing.T) {
+		s3fs, err := stream.New[stream.PreSignedUrl]("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Signer(s3PresignPutObjectError),
+		)
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Must(it.Nil(err))
+
+		meta := s3fs.StatSys(fi)
+		it.Then(t).Should(
+			it.Equal(meta.PreSignedUrl, ""),
+		)
+
+		err = fd.Close()
+		it.Then(t).Must(it.Nil(err))
+	})
+
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	data := strings.Repeat("x", 64*1024*1024)
+	api := mocks.GetObjectRange{
+		Mock:    mocks.Mock[s3.GetObjectOutput]{ExpectKey: file[1:]},
+		Content: data,
+	}
+
+	for name, opt := range map[string][]stream.Option{
+		"Single":   {stream.WithS3(api)},
+		"Parallel": {stream.WithS3(api), stream.WithParallelDownload(8*1024*1024, 8)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			s3fs, err := stream.NewFS("test", opt...)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := s3fs.ReadFile(file); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTarTo(t *testing.T) {
+	t.Run("TarTo", func(t *testing.T) {
+		bucket := mocks.NewBucket(map[string]string{
+			"a/b/": "", "a/b/c": content, "a/x": "Hello", "z/y": content,
+		})
+		s3fs, err := stream.NewFS("test", stream.WithS3(bucket))
+		it.Then(t).Must(it.Nil(err))
+
+		buf := &bytes.Buffer{}
+		err = s3fs.TarTo("/a/", buf)
+		it.Then(t).Must(it.Nil(err))
+
+		files := map[string]string{}
+		tr := tar.NewReader(buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			it.Then(t).Must(it.Nil(err))
+
+			val, err := io.ReadAll(tr)
+			it.Then(t).Must(
+				it.Nil(err),
+				it.Equal(hdr.Size, int64(len(val))),
+			)
+			files[hdr.Name] = string(val)
+		}
 
 		it.Then(t).Should(
-			it.Error(s3fs.Stat(file)),
+			it.Equal(len(files), 2),
+			it.Equal(files["b/c"], content),
+			it.Equal(files["x"], "Hello"),
 		)
 	})
 
-	t.Run("Stat/Error/InvalidPath", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
-		)
-		it.Then(t).Should(it.Nil(err))
+	t.Run("Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(mocks.NewBucket(nil)))
+		it.Then(t).Must(it.Nil(err))
 
-		it.Then(t).Should(
-			it.Error(s3fs.Stat("invalid..key/")),
-		)
+		err = s3fs.TarTo(file, io.Discard)
+		it.Then(t).ShouldNot(it.Nil(err))
 	})
 
-	t.Run("Stat/Error/NotFound", func(t *testing.T) {
+	t.Run("Error/Checksum", func(t *testing.T) {
+		bucket := mocks.NewBucket(map[string]string{"a/x": content})
+		bucket.Checksums = map[string]string{
+			"a/x": base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		}
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3HeadObjectNotFound),
+			stream.WithS3(bucket),
+			stream.WithChecksumValidation(true),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
-		_, err = s3fs.Stat(file)
-		it.Then(t).Should(
-			it.True(errors.Is(err, fs.ErrNotExist)),
-		)
+		err = s3fs.TarTo("/a/", io.Discard)
+		it.Then(t).Should(it.True(errors.Is(err, stream.ErrChecksumMismatch)))
 	})
+}
 
-	t.Run("File/Stat", func(t *testing.T) {
+func TestRemoveDir(t *testing.T) {
+	t.Run("RemoveDir", func(t *testing.T) {
+		bucket := mocks.NewBucket(map[string]string{
+			"a/": "", "a/b/": "", "a/b/c": content, "a/x": content, "ab": content, "z/y": content,
+		})
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+			stream.WithS3(bucket),
+			stream.WithListingLimit(2),
 		)
-		it.Then(t).Should(it.Nil(err))
-
-		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
-		it.Then(t).Must(it.Nil(err))
+		err = s3fs.RemoveDir("/a/")
 		it.Then(t).Should(
-			it.Equal(fi.Name(), file),
-			it.Equal(fi.Size(), size),
-			it.Equiv(fi.ModTime(), modified),
-			it.Equal(fi.IsDir(), false),
+			it.Nil(err),
+			it.Seq(bucket.Keys()).Equal("ab", "z/y"),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("Error/NotDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(mocks.NewBucket(nil)))
 		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.RemoveDir(file)
+		it.Then(t).ShouldNot(it.Nil(err))
 	})
 
-	t.Run("File/Stat.Read", func(t *testing.T) {
+	t.Run("Error/Listing", func(t *testing.T) {
 		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+			stream.WithS3(s3ListObjectError),
 		)
-		it.Then(t).Should(it.Nil(err))
-
-		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		_, err = io.ReadAll(fd)
+		err = s3fs.RemoveDir(file + "/")
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+
+	t.Run("Error/Timeout", func(t *testing.T) {
+		delay := 5 * time.Second
+		m := s3ListObject
+		m.Delay = &delay
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(m),
+			stream.WithIOTimeout(10*time.Millisecond),
+		)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
+		err = s3fs.RemoveDir(dir)
+		it.Then(t).Should(it.True(errors.Is(err, context.DeadlineExceeded)))
+	})
+}
+
+func TestTarFrom(t *testing.T) {
+	archive := func(files ...string) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "b/", Mode: 0755})
+		for _, name := range files {
+			tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0644})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		return buf
+	}
+
+	t.Run("TarFrom", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test", stream.WithS3(bucket), stream.WithS3Upload(bucket))
 		it.Then(t).Must(it.Nil(err))
+
+		err = s3fs.TarFrom("/a/", archive("b/c", "x"))
 		it.Then(t).Should(
-			it.Equal(fi.Name(), file),
-			it.Equal(fi.Size(), size),
-			it.Equiv(fi.ModTime(), modified),
-			it.Equal(fi.IsDir(), false),
+			it.Nil(err),
+			it.Seq(bucket.Keys()).Equal("a/b/c", "a/x"),
+			it.Equal(bucket.Objects["a/b/c"], content),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("TarTo/TarFrom", func(t *testing.T) {
+		bucket := mocks.NewBucket(map[string]string{"a/b/c": content, "a/x": "Hello"})
+		s3fs, err := stream.NewFS("test", stream.WithS3(bucket), stream.WithS3Upload(bucket))
 		it.Then(t).Must(it.Nil(err))
-	})
 
-	t.Run("Dir/Stat", func(t *testing.T) {
-		s3fs, err := stream.NewFS("test",
-			stream.WithS3(s3GetObject),
+		buf := &bytes.Buffer{}
+		it.Then(t).Must(it.Nil(s3fs.TarTo("/a/", buf)))
+
+		err = s3fs.TarFrom("/z/", buf)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(bucket.Objects["z/b/c"], content),
+			it.Equal(bucket.Objects["z/x"], "Hello"),
 		)
-		it.Then(t).Should(it.Nil(err))
+	})
 
-		fd, err := s3fs.Open(dir)
+	t.Run("Error/Truncated", func(t *testing.T) {
+		bucket := mocks.NewBucket(nil)
+		s3fs, err := stream.NewFS("test", stream.WithS3(bucket), stream.WithS3Upload(bucket))
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
-		it.Then(t).Must(it.Nil(err))
+		buf := archive("x")
+		err = s3fs.TarFrom("/a/", bytes.NewReader(buf.Bytes()[:512+5]))
 		it.Then(t).Should(
-			it.Equal(fi.Name(), dir),
-			it.Equal(fi.Size(), 0),
-			it.Equiv(fi.ModTime(), time.Time{}),
-			it.Equal(fi.IsDir(), true),
+			it.True(errors.Is(err, io.ErrUnexpectedEOF)),
+			it.Equal(len(bucket.Keys()), 0),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("Error/NotDir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(mocks.NewBucket(nil)))
 		it.Then(t).Must(it.Nil(err))
-	})
 
+		err = s3fs.TarFrom(file, archive("x"))
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
 }
 
-type Note struct {
-	stream.SystemMetadata
-	Author  string
-	Chapter string
-}
+func TestChecksumValidation(t *testing.T) {
+	getObject := func(checksum string) mocks.GetObject {
+		return mocks.GetObject{
+			Mock: mocks.Mock[s3.GetObjectOutput]{
+				ExpectKey: file[1:],
+				ReturnVal: &s3.GetObjectOutput{
+					Body:           io.NopCloser(strings.NewReader(content)),
+					ContentLength:  aws.Int64(int64(len(content))),
+					ChecksumSHA256: aws.String(checksum),
+				},
+			},
+			ExpectInput: func(req *s3.GetObjectInput) error {
+				if req.ChecksumMode != types.ChecksumModeEnabled {
+					return fmt.Errorf("unexpected ChecksumMode %s", req.ChecksumMode)
+				}
+				return nil
+			},
+		}
+	}
 
-func TestMetadata(t *testing.T) {
-	t.Run("Stat", func(t *testing.T) {
-		s3fs, err := stream.New[Note]("test",
-			stream.WithS3(s3HeadObject),
+	sha := sha256.Sum256([]byte(content))
+	checksum := base64.StdEncoding.EncodeToString(sha[:])
+
+	t.Run("Valid", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(getObject(checksum)),
+			stream.WithChecksumValidation(true),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
-		fi, err := s3fs.Stat(file)
+		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
-		it.Then(t).ShouldNot(
-			it.Nil(fi.Sys()),
-		).
-			Should(
-				it.Equiv(meta, &note),
-			)
+		buf, err := io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+			it.Nil(fd.Close()),
+		)
 	})
 
-	t.Run("File/Stat", func(t *testing.T) {
-		s3fs, err := stream.New[Note]("test",
-			stream.WithS3(s3GetObject),
+	t.Run("Mismatch", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(getObject(base64.StdEncoding.EncodeToString(make([]byte, 32)))),
+			stream.WithChecksumValidation(true),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
 		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
+		_, err = io.ReadAll(fd)
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
+		err = fd.Close()
 		it.Then(t).Should(
-			it.Equiv(meta, &note),
+			it.True(errors.Is(err, stream.ErrChecksumMismatch)),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("Disabled", func(t *testing.T) {
+		mock := getObject("corrupted")
+		mock.ExpectInput = func(req *s3.GetObjectInput) error {
+			if req.ChecksumMode != "" {
+				return fmt.Errorf("unexpected ChecksumMode %s", req.ChecksumMode)
+			}
+			return nil
+		}
+
+		s3fs, err := stream.NewFS("test", stream.WithS3(mock))
+		it.Then(t).Must(it.Nil(err))
+
+		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.ReadAll(fd)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(fd.Close()),
+		)
 	})
+}
 
-	t.Run("File/Stat.Read", func(t *testing.T) {
+func TestGetObject(t *testing.T) {
+	t.Run("GetObject", func(t *testing.T) {
 		s3fs, err := stream.New[Note]("test",
-			stream.WithS3(s3GetObject),
+			stream.WithS3(mocks.GetObject{
+				Mock: mocks.Mock[s3.GetObjectOutput]{
+					ExpectKey: file[1:],
+					ReturnVal: &s3.GetObjectOutput{
+						Body:          io.NopCloser(strings.NewReader(content)),
+						ContentLength: aws.Int64(size),
+						ContentType:   aws.String("text/plain"),
+						LastModified:  aws.Time(modified),
+						Metadata:      map[string]string{"author": "fogfish"},
+					},
+				},
+			}),
 		)
-		it.Then(t).Should(it.Nil(err))
-
-		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		_, err = io.ReadAll(fd)
+		obj, err := s3fs.GetObject(file)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
+		buf, err := io.ReadAll(obj.Body)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+			it.Equal(obj.Info.Size(), size),
+			it.Equal(obj.Info.ModTime(), modified),
+			it.Equal(obj.Attr.Author, "fogfish"),
+			it.Equal(obj.Attr.ContentType, "text/plain"),
+			it.Nil(obj.Body.Close()),
+		)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3GetObjectNotFound))
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
+		_, err = s3fs.GetObject(file)
+		var e *fs.PathError
 		it.Then(t).Should(
-			it.Equiv(meta, &note),
+			it.True(errors.Is(err, fs.ErrNotExist)),
+			it.True(errors.As(err, &e)),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("Error/Dir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3GetObject))
 		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.GetObject(dir)
+		it.Then(t).ShouldNot(it.Nil(err))
 	})
 }
 
-func TestPreSign(t *testing.T) {
-	t.Run("PreSignUrl", func(t *testing.T) {
-		s3fs, err := stream.New[stream.PreSignedUrl]("test",
-			stream.WithS3(s3HeadObject),
-			stream.WithS3Signer(s3PresignGetObject),
+func TestCreateExclusive(t *testing.T) {
+	create := func(s3fs *stream.FileSystem[struct{}], exclusive bool) error {
+		var (
+			fd  stream.File
+			err error
 		)
-		it.Then(t).Should(it.Nil(err))
+		if exclusive {
+			fd, err = s3fs.CreateExclusive(file, nil)
+		} else {
+			fd, err = s3fs.Create(file, nil)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(fd, content); err != nil {
+			return err
+		}
+
+		return fd.Close()
+	}
 
-		fi, err := s3fs.Stat(file)
+	cfg := func(client *recordingClient) aws.Config {
+		return aws.Config{
+			Region:      "eu-west-1",
+			Credentials: aws.AnonymousCredentials{},
+			HTTPClient:  client,
+		}
+	}
+
+	t.Run("IfNoneMatch", func(t *testing.T) {
+		client := &recordingClient{}
+		s3fs, err := stream.NewFromConfig[struct{}]("test", cfg(client))
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
+		err = create(s3fs, true)
 		it.Then(t).Should(
-			it.Equal(meta.PreSignedUrl, presignedUrl),
+			it.Nil(err),
+			it.Equal(client.req.Method, http.MethodPut),
+			it.Equal(client.req.Header.Get("If-None-Match"), "*"),
 		)
 	})
 
-	t.Run("File/Read/PreSignUrl", func(t *testing.T) {
-		s3fs, err := stream.New[stream.PreSignedUrl]("test",
-			stream.WithS3(s3GetObject),
-			stream.WithS3Signer(s3PresignGetObject),
+	t.Run("IfNoneMatch/Small", func(t *testing.T) {
+		client := &recordingClient{}
+		s3fs, err := stream.NewFromConfig[struct{}]("test", cfg(client),
+			stream.WithBufferSmallWrites(1024),
 		)
-		it.Then(t).Should(it.Nil(err))
-
-		fd, err := s3fs.Open(file)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
+		err = create(s3fs, true)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(client.req.Header.Get("If-None-Match"), "*"),
+		)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		client := &recordingClient{}
+		s3fs, err := stream.NewFromConfig[struct{}]("test", cfg(client))
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
+		err = create(s3fs, false)
 		it.Then(t).Should(
-			it.Equal(meta.PreSignedUrl, presignedUrl),
+			it.Nil(err),
+			it.Equal(client.req.Header.Get("If-None-Match"), ""),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("Error/Exist", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(s3PutObject),
+			stream.WithS3Upload(mocks.PutObject{
+				Mock: mocks.Mock[manager.UploadOutput]{
+					ExpectKey: file[1:],
+					ReturnErr: &smithy.GenericAPIError{Code: "PreconditionFailed"},
+				},
+			}),
+		)
 		it.Then(t).Must(it.Nil(err))
-	})
 
-	t.Run("File/Read/PreSignUrl/Error", func(t *testing.T) {
-		s3fs, err := stream.New[stream.PreSignedUrl]("test",
-			stream.WithS3(s3GetObject),
-			stream.WithS3Signer(s3PresignGetObjectError),
+		err = create(s3fs, true)
+		var e *fs.PathError
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrExist)),
+			it.True(errors.As(err, &e)),
 		)
-		it.Then(t).Should(it.Nil(err))
+	})
+}
 
-		fd, err := s3fs.Open(file)
+func TestWriteCancel(t *testing.T) {
+	t.Run("Cancel/Context", func(t *testing.T) {
+		client := &mocks.Multipart{}
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(client),
+			stream.WithS3Upload(manager.NewUploader(client)),
+		)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
+		ctx, cancel := context.WithCancel(context.Background())
+		fd, err := s3fs.CreateCtx(ctx, file, nil)
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
+		// the first part is uploaded, the upload is in progress
+		chunk := make([]byte, 64*1024)
+		for i := 0; i < 100; i++ {
+			_, err = fd.Write(chunk)
+			it.Then(t).Must(it.Nil(err))
+		}
+
+		cancel()
+
+		for i := 0; i < 1000 && err == nil; i++ {
+			_, err = fd.Write(chunk)
+		}
+
+		var e *fs.PathError
 		it.Then(t).Should(
-			it.Equal(meta.PreSignedUrl, ""),
+			it.True(errors.Is(err, context.Canceled)),
+			it.True(errors.As(err, &e)),
 		)
 
 		err = fd.Close()
-		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.True(errors.Is(err, context.Canceled)),
+			it.True(errors.As(err, &e)),
+			it.Seq(client.Aborted()).Equal("upload"),
+		)
 	})
 
-	t.Run("File/Write/PreSignUrl", func(t *testing.T) {
-		s3fs, err := stream.New[stream.PreSignedUrl]("test",
-			stream.WithS3Signer(s3PresignPutObject),
+	t.Run("Complete", func(t *testing.T) {
+		client := &mocks.Multipart{}
+		s3fs, err := stream.NewFS("test",
+			stream.WithS3(client),
+			stream.WithS3Upload(manager.NewUploader(client)),
 		)
-		it.Then(t).Should(it.Nil(err))
+		it.Then(t).Must(it.Nil(err))
 
 		fd, err := s3fs.Create(file, nil)
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
-		it.Then(t).Must(it.Nil(err))
+		chunk := make([]byte, 64*1024)
+		for i := 0; i < 100; i++ {
+			_, err = fd.Write(chunk)
+			it.Then(t).Must(it.Nil(err))
+		}
 
-		meta := s3fs.StatSys(fi)
 		it.Then(t).Should(
-			it.Equal(meta.PreSignedUrl, presignedUrl),
+			it.Nil(fd.Close()),
+			it.Equal(len(client.Aborted()), 0),
 		)
+	})
+}
 
-		err = fd.Close()
+func TestExists(t *testing.T) {
+	t.Run("Exists", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3HeadObject))
 		it.Then(t).Must(it.Nil(err))
-	})
 
-	t.Run("File/Write/PreSignUrl/Error", func(t *testing.T) {
-		s3fs, err := stream.New[stream.PreSignedUrl]("test",
-			stream.WithS3Signer(s3PresignPutObjectError),
+		has, err := s3fs.Exists(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.True(has),
 		)
-		it.Then(t).Should(it.Nil(err))
+	})
 
-		fd, err := s3fs.Create(file, nil)
+	t.Run("NotFound", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3HeadObjectNotFound))
 		it.Then(t).Must(it.Nil(err))
 
-		fi, err := fd.Stat()
+		has, err := s3fs.Exists(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.True(!has),
+		)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3HeadObjectError))
 		it.Then(t).Must(it.Nil(err))
 
-		meta := s3fs.StatSys(fi)
+		has, err := s3fs.Exists(file)
 		it.Then(t).Should(
-			it.Equal(meta.PreSignedUrl, ""),
+			it.True(!has),
+		).ShouldNot(
+			it.Nil(err),
 		)
+	})
 
-		err = fd.Close()
+	t.Run("Error/Dir", func(t *testing.T) {
+		s3fs, err := stream.NewFS("test", stream.WithS3(s3HeadObject))
 		it.Then(t).Must(it.Nil(err))
-	})
 
+		_, err = s3fs.Exists(dir)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
 }
@@ -0,0 +1,255 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package mocks
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/fogfish/stream"
+)
+
+// Bucket is in-memory S3 bucket, it keeps state across requests
+type Bucket struct {
+	stream.S3
+	mu      sync.Mutex
+	Objects map[string]string
+	Tagging map[string]string
+	Classes map[string]types.StorageClass
+
+	// Checksums of objects (SHA256) returned by GetObject, if defined
+	Checksums map[string]string
+}
+
+func NewBucket(objects map[string]string) *Bucket {
+	if objects == nil {
+		objects = map[string]string{}
+	}
+
+	return &Bucket{
+		Objects: objects,
+		Tagging: map[string]string{},
+		Classes: map[string]types.StorageClass{},
+	}
+}
+
+// Keys of the bucket in lexicographical order
+func (b *Bucket) Keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.keys()
+}
+
+func (b *Bucket) keys() []string {
+	keys := make([]string, 0, len(b.Objects))
+	for key := range b.Objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func (b *Bucket) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	val, has := b.Objects[aws.ToString(params.Key)]
+	if !has {
+		return nil, &types.NotFound{}
+	}
+
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(val))),
+		StorageClass:  b.Classes[aws.ToString(params.Key)],
+	}, nil
+}
+
+func (b *Bucket) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	val, has := b.Objects[aws.ToString(params.Key)]
+	if !has {
+		return nil, &types.NoSuchKey{}
+	}
+
+	return &s3.GetObjectOutput{
+		Body:           io.NopCloser(strings.NewReader(val)),
+		ContentLength:  aws.Int64(int64(len(val))),
+		StorageClass:   b.Classes[aws.ToString(params.Key)],
+		ChecksumSHA256: b.checksum(aws.ToString(params.Key)),
+	}, nil
+}
+
+func (b *Bucket) checksum(key string) *string {
+	if sum, has := b.Checksums[key]; has {
+		return aws.String(sum)
+	}
+	return nil
+}
+
+func (b *Bucket) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := b.put(params); err != nil {
+		return nil, err
+	}
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (b *Bucket) Upload(ctx context.Context, params *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	if err := b.put(params); err != nil {
+		return nil, err
+	}
+
+	return &manager.UploadOutput{}, nil
+}
+
+func (b *Bucket) put(params *s3.PutObjectInput) error {
+	buf := &bytes.Buffer{}
+	if params.Body != nil {
+		if _, err := buf.ReadFrom(params.Body); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Objects[aws.ToString(params.Key)] = buf.String()
+	if params.Tagging != nil {
+		b.Tagging[aws.ToString(params.Key)] = aws.ToString(params.Tagging)
+	}
+	if params.StorageClass != "" {
+		b.Classes[aws.ToString(params.Key)] = params.StorageClass
+	}
+	return nil
+}
+
+func (b *Bucket) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, has := b.Objects[aws.ToString(params.Key)]; !has {
+		return nil, &types.NoSuchKey{}
+	}
+
+	tags, err := url.ParseQuery(b.Tagging[aws.ToString(params.Key)])
+	if err != nil {
+		return nil, err
+	}
+
+	val := &s3.GetObjectTaggingOutput{TagSet: []types.Tag{}}
+	for key := range tags {
+		val.TagSet = append(val.TagSet, types.Tag{Key: aws.String(key), Value: aws.String(tags.Get(key))})
+	}
+	return val, nil
+}
+
+func (b *Bucket) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.Objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (b *Bucket) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, obj := range params.Delete.Objects {
+		delete(b.Objects, aws.ToString(obj.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func (b *Bucket) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	source := aws.ToString(params.CopySource)
+	if i := strings.IndexByte(source, '/'); i != -1 {
+		source = source[i+1:]
+	}
+
+	val, has := b.Objects[source]
+	if !has {
+		return nil, &types.NoSuchKey{}
+	}
+
+	b.Objects[aws.ToString(params.Key)] = val
+	return &s3.CopyObjectOutput{}, nil
+}
+
+// ListObjectsV2 supports Prefix, Delimiter, MaxKeys, StartAfter and
+// ContinuationToken (the last key of previous page)
+func (b *Bucket) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prefix := aws.ToString(params.Prefix)
+	delimiter := aws.ToString(params.Delimiter)
+	after := aws.ToString(params.StartAfter)
+	if params.ContinuationToken != nil {
+		after = aws.ToString(params.ContinuationToken)
+	}
+
+	limit := int(aws.ToInt32(params.MaxKeys))
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	val := &s3.ListObjectsV2Output{}
+	seen := map[string]struct{}{}
+	last := ""
+	for _, key := range b.keys() {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+
+		if int(aws.ToInt32(val.KeyCount)) == limit {
+			val.IsTruncated = aws.Bool(true)
+			val.NextContinuationToken = aws.String(last)
+			break
+		}
+
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i != -1 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				last = key
+				if _, has := seen[common]; !has {
+					seen[common] = struct{}{}
+					val.CommonPrefixes = append(val.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(common)})
+					val.KeyCount = aws.Int32(aws.ToInt32(val.KeyCount) + 1)
+				}
+				continue
+			}
+		}
+
+		last = key
+		val.Contents = append(val.Contents, types.Object{
+			Key:  aws.String(key),
+			Size: aws.Int64(int64(len(b.Objects[key]))),
+		})
+		val.KeyCount = aws.Int32(aws.ToInt32(val.KeyCount) + 1)
+	}
+
+	return val, nil
+}
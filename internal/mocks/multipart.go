@@ -0,0 +1,104 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package mocks
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/fogfish/stream"
+)
+
+// Multipart is S3 client for manager.Uploader, it accepts parts and
+// records aborted uploads and number of requests
+type Multipart struct {
+	stream.S3
+	mu      sync.Mutex
+	aborted []string
+	calls   map[string]int
+}
+
+// Calls is number of requests of the operation (e.g. PutObject)
+func (mock *Multipart) Calls(op string) int {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	return mock.calls[op]
+}
+
+func (mock *Multipart) call(op string) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	if mock.calls == nil {
+		mock.calls = make(map[string]int)
+	}
+	mock.calls[op]++
+}
+
+// Aborted upload ids
+func (mock *Multipart) Aborted() []string {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	return append([]string{}, mock.aborted...)
+}
+
+func (mock *Multipart) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	mock.call("PutObject")
+
+	if _, err := io.Copy(io.Discard, params.Body); err != nil {
+		return nil, err
+	}
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (mock *Multipart) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	mock.call("CreateMultipartUpload")
+
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+}
+
+func (mock *Multipart) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	mock.call("UploadPart")
+
+	if _, err := io.Copy(io.Discard, params.Body); err != nil {
+		return nil, err
+	}
+
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func (mock *Multipart) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	mock.call("CompleteMultipartUpload")
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (mock *Multipart) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	mock.call("AbortMultipartUpload")
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	mock.aborted = append(mock.aborted, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
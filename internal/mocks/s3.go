@@ -12,6 +12,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -19,6 +20,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/fogfish/stream"
 )
 
@@ -31,6 +33,9 @@ type Mock[T any] struct {
 	ExpectVal string
 	ReturnVal *T
 	ReturnErr error
+
+	// ExpectBucketOwner asserts ExpectedBucketOwner of the request, if defined
+	ExpectBucketOwner string
 }
 
 func (mock Mock[T]) Assert(ctx context.Context, inputKey *string) error {
@@ -50,6 +55,14 @@ func (mock Mock[T]) Assert(ctx context.Context, inputKey *string) error {
 	return nil
 }
 
+func (mock Mock[T]) AssertBucketOwner(owner *string) error {
+	if mock.ExpectBucketOwner != "" && aws.ToString(owner) != mock.ExpectBucketOwner {
+		return fmt.Errorf("expected bucket owner %s, got %s", mock.ExpectBucketOwner, aws.ToString(owner))
+	}
+
+	return nil
+}
+
 //
 
 type HeadObject struct{ Mock[s3.HeadObjectOutput] }
@@ -59,6 +72,10 @@ func (mock HeadObject) HeadObject(ctx context.Context, input *s3.HeadObjectInput
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(input.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
@@ -70,15 +87,60 @@ func (mock HeadObject) HeadObject(ctx context.Context, input *s3.HeadObjectInput
 	return mock.ReturnVal, nil
 }
 
+// ListObjectsV2 is served by composed S3 client, if any, otherwise the listing
+// is empty (e.g. probe of directory for missing file).
+func (mock HeadObject) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if mock.S3 != nil {
+		return mock.S3.ListObjectsV2(ctx, params, optFns...)
+	}
+
+	return &s3.ListObjectsV2Output{}, nil
+}
+
+//
+
+type GetObjectTagging struct {
+	Mock[s3.GetObjectTaggingOutput]
+}
+
+func (mock GetObjectTagging) GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput, opts ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if err := mock.Assert(ctx, input.Key); err != nil {
+		return nil, err
+	}
+
+	if mock.ReturnErr != nil {
+		return nil, mock.ReturnErr
+	}
+
+	if mock.ReturnVal == nil {
+		return nil, &types.NoSuchKey{}
+	}
+
+	return mock.ReturnVal, nil
+}
+
 //
 
-type GetObject struct{ Mock[s3.GetObjectOutput] }
+type GetObject struct {
+	Mock[s3.GetObjectOutput]
+	ExpectInput func(*s3.GetObjectInput) error
+}
 
 func (mock GetObject) GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
 	if err := mock.Assert(ctx, input.Key); err != nil {
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(input.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
+	if mock.ExpectInput != nil {
+		if err := mock.ExpectInput(input); err != nil {
+			return nil, err
+		}
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
@@ -90,6 +152,46 @@ func (mock GetObject) GetObject(ctx context.Context, input *s3.GetObjectInput, o
 	return mock.ReturnVal, nil
 }
 
+// GetObjectRange serves byte ranges ("bytes=from-to" or suffix "bytes=-n")
+// of the content
+type GetObjectRange struct {
+	Mock[s3.GetObjectOutput]
+	Content string
+}
+
+func (mock GetObjectRange) GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if err := mock.Assert(ctx, input.Key); err != nil {
+		return nil, err
+	}
+
+	if err := mock.AssertBucketOwner(input.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
+	size := int64(len(mock.Content))
+	from, to := int64(0), size-1
+	if input.Range != nil {
+		var n int64
+		if _, err := fmt.Sscanf(aws.ToString(input.Range), "bytes=-%d", &n); err == nil {
+			from = max(size-n, 0)
+		} else {
+			fmt.Sscanf(aws.ToString(input.Range), "bytes=%d-%d", &from, &to)
+			to = min(to, size-1)
+		}
+	}
+
+	if from >= size {
+		return nil, &smithy.GenericAPIError{Code: "InvalidRange"}
+	}
+
+	body := mock.Content[from : to+1]
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentRange:  aws.String(fmt.Sprintf("bytes %d-%d/%d", from, to, size)),
+	}, nil
+}
+
 //
 
 type ListObject struct{ Mock[s3.ListObjectsV2Output] }
@@ -99,6 +201,10 @@ func (mock ListObject) ListObjectsV2(ctx context.Context, params *s3.ListObjects
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
@@ -108,6 +214,33 @@ func (mock ListObject) ListObjectsV2(ctx context.Context, params *s3.ListObjects
 
 //
 
+// ListPrefixes serves delimited listing of the tree, given as prefix to
+// common prefixes of the next level.
+type ListPrefixes struct {
+	stream.S3
+	Tree map[string][]string
+}
+
+func (mock ListPrefixes) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if aws.ToString(params.Delimiter) != "/" {
+		return nil, fmt.Errorf("expected delimiter /, got %s", aws.ToString(params.Delimiter))
+	}
+
+	seq, has := mock.Tree[aws.ToString(params.Prefix)]
+	if !has {
+		return &s3.ListObjectsV2Output{KeyCount: aws.Int32(0)}, nil
+	}
+
+	val := &s3.ListObjectsV2Output{KeyCount: aws.Int32(int32(len(seq)))}
+	for _, prefix := range seq {
+		val.CommonPrefixes = append(val.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(prefix)})
+	}
+
+	return val, nil
+}
+
+//
+
 type DeleteObject struct{ Mock[s3.DeleteObjectOutput] }
 
 func (mock DeleteObject) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
@@ -115,6 +248,10 @@ func (mock DeleteObject) DeleteObject(ctx context.Context, params *s3.DeleteObje
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
@@ -124,13 +261,89 @@ func (mock DeleteObject) DeleteObject(ctx context.Context, params *s3.DeleteObje
 
 //
 
-type CopyObject struct{ Mock[s3.CopyObjectOutput] }
+// DeleteObjects fails keys listed at Failed, other keys are deleted. Calls
+// records keys of each request.
+type DeleteObjects struct {
+	Mock[s3.DeleteObjectsOutput]
+	Failed map[string]string
+	Calls  *[][]string
+}
+
+func (mock DeleteObjects) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
+	if mock.ReturnErr != nil {
+		return nil, mock.ReturnErr
+	}
+
+	keys := make([]string, 0, len(params.Delete.Objects))
+	val := &s3.DeleteObjectsOutput{}
+	for _, obj := range params.Delete.Objects {
+		key := aws.ToString(obj.Key)
+		keys = append(keys, key)
+
+		if code, has := mock.Failed[key]; has {
+			val.Errors = append(val.Errors, types.Error{
+				Key:     aws.String(key),
+				Code:    aws.String(code),
+				Message: aws.String("failed"),
+			})
+		}
+	}
+
+	if mock.Calls != nil {
+		*mock.Calls = append(*mock.Calls, keys)
+	}
+
+	return val, nil
+}
+
+//
+
+type CopyObject struct {
+	Mock[s3.CopyObjectOutput]
+	ExpectInput func(*s3.CopyObjectInput) error
+}
 
 func (mock CopyObject) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
 	if err := mock.Assert(ctx, params.Key); err != nil {
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
+	if mock.ExpectInput != nil {
+		if err := mock.ExpectInput(params); err != nil {
+			return nil, err
+		}
+	}
+
+	if mock.ReturnErr != nil {
+		return nil, mock.ReturnErr
+	}
+
+	return mock.ReturnVal, nil
+}
+
+//
+
+type ListMultipartUploads struct {
+	Mock[s3.ListMultipartUploadsOutput]
+}
+
+func (mock ListMultipartUploads) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	if err := mock.Assert(ctx, params.Prefix); err != nil {
+		return nil, err
+	}
+
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
@@ -140,17 +353,58 @@ func (mock CopyObject) CopyObject(ctx context.Context, params *s3.CopyObjectInpu
 
 //
 
-type PutObject struct{ Mock[manager.UploadOutput] }
+type AbortMultipartUpload struct {
+	Mock[s3.AbortMultipartUploadOutput]
+}
+
+func (mock AbortMultipartUpload) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if err := mock.Assert(ctx, params.Key); err != nil {
+		return nil, err
+	}
+
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
+	if mock.ReturnErr != nil {
+		return nil, mock.ReturnErr
+	}
+
+	return mock.ReturnVal, nil
+}
+
+//
+
+type PutObject struct {
+	Mock[manager.UploadOutput]
+	ExpectInput func(*s3.PutObjectInput) error
+}
+
+func (mock PutObject) assertInput(input *s3.PutObjectInput) error {
+	if mock.ExpectInput == nil {
+		return nil
+	}
+
+	return mock.ExpectInput(input)
+}
 
 func (mock PutObject) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
 	if err := mock.Assert(ctx, input.Key); err != nil {
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(input.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
 
+	if err := mock.assertInput(input); err != nil {
+		return nil, err
+	}
+
 	buf, err := io.ReadAll(input.Body)
 	if err != nil {
 		return nil, err
@@ -163,6 +417,35 @@ func (mock PutObject) Upload(ctx context.Context, input *s3.PutObjectInput, opts
 	return mock.ReturnVal, nil
 }
 
+func (mock PutObject) PutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := mock.Assert(ctx, input.Key); err != nil {
+		return nil, err
+	}
+
+	if err := mock.AssertBucketOwner(input.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
+	if mock.ReturnErr != nil {
+		return nil, mock.ReturnErr
+	}
+
+	if err := mock.assertInput(input); err != nil {
+		return nil, err
+	}
+
+	buf, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(buf) != mock.ExpectVal {
+		return nil, fmt.Errorf("expected val %s, got %s", mock.ExpectVal, string(buf))
+	}
+
+	return &s3.PutObjectOutput{}, nil
+}
+
 //
 
 type PresignGetObject struct{ Mock[v4.PresignedHTTPRequest] }
@@ -172,6 +455,10 @@ func (mock PresignGetObject) PresignGetObject(ctx context.Context, params *s3.Ge
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
@@ -188,9 +475,33 @@ func (mock PresignPutObject) PresignPutObject(ctx context.Context, params *s3.Pu
 		return nil, err
 	}
 
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return nil, err
+	}
+
 	if mock.ReturnErr != nil {
 		return nil, mock.ReturnErr
 	}
 
 	return mock.ReturnVal, nil
 }
+
+//
+
+type Waiter struct{ Mock[s3.HeadObjectOutput] }
+
+func (mock Waiter) Wait(ctx context.Context, params *s3.HeadObjectInput, maxWaitDur time.Duration, optFns ...func(*s3.ObjectExistsWaiterOptions)) error {
+	if mock.Delay != nil && *mock.Delay > maxWaitDur {
+		return fmt.Errorf("exceeded max wait time for ObjectExists waiter")
+	}
+
+	if err := mock.Assert(ctx, params.Key); err != nil {
+		return err
+	}
+
+	if err := mock.AssertBucketOwner(params.ExpectedBucketOwner); err != nil {
+		return err
+	}
+
+	return mock.ReturnErr
+}
@@ -0,0 +1,26 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package lfs
+
+import (
+	"os"
+
+	"github.com/fogfish/stream"
+)
+
+// OpenDevFull returns writer backed by /dev/full, each write fails with ENOSPC.
+// The file is marked closed so that Close and Cancel never touch the device.
+func OpenDevFull() (stream.File, func() error, error) {
+	fd, err := os.OpenFile("/dev/full", os.O_WRONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &atomicFile{File: fd, path: "/full", closed: true}, fd.Close, nil
+}
@@ -9,13 +9,17 @@
 package lfs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fogfish/stream"
@@ -34,6 +38,8 @@ var (
 	_ stream.CreateFS[struct{}] = (*FileSystem)(nil)
 	_ stream.RemoveFS           = (*FileSystem)(nil)
 	_ stream.CopyFS             = (*FileSystem)(nil)
+	_ stream.AtomicFS           = (*FileSystem)(nil)
+	_ stream.RenameFS           = (*FileSystem)(nil)
 )
 
 // Create local file system instance, mounting dir.
@@ -65,9 +71,14 @@ func NewTempFS(root string, pattern string) (*FileSystem, error) {
 	return New(dir)
 }
 
+// Unwrap returns the underlying os.DirFS file system mounted at Root.
+func (fsys *FileSystem) Unwrap() fs.FS { return fsys.fs }
+
 // To open the file for writing use `Create` function giving the absolute path
 // starting with `/`, the returned file descriptor is a composite of
-// `io.Writer`, `io.Closer` and `stream.Stat`.
+// `io.Writer`, `io.Closer` and `stream.Stat`. The file is written into
+// hidden temporary file next to the target and renamed on Close, it is not
+// visible until then. ReadDir and Glob skip temporary files.
 func (fsys *FileSystem) Create(path string, attr *struct{}) (stream.File, error) {
 	if err := stream.RequireValidFile("create", path); err != nil {
 		return nil, err
@@ -79,15 +90,37 @@ func (fsys *FileSystem) Create(path string, attr *struct{}) (stream.File, error)
 
 func (fsys *FileSystem) osCreate(ctx, path string) (stream.File, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return nil, &fs.PathError{
+		if errors.Is(err, syscall.ENOTDIR) {
+			err = fmt.Errorf("%w: %w", stream.ErrNotDir, err)
+		}
+
+		return nil, noSpace(&fs.PathError{
 			Op:   ctx,
 			Path: path,
 			Err:  err,
+		})
+	}
+
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return nil, &fs.PathError{
+			Op:   ctx,
+			Path: path,
+			Err:  syscall.EISDIR,
 		}
 	}
 
-	fd, err := os.Create(path)
+	fd, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"+tempSuffix)
 	if err != nil {
+		return nil, noSpace(&fs.PathError{
+			Op:   ctx,
+			Path: path,
+			Err:  err,
+		})
+	}
+
+	if err := fd.Chmod(0644); err != nil {
+		fd.Close()
+		os.Remove(fd.Name())
 		return nil, &fs.PathError{
 			Op:   ctx,
 			Path: path,
@@ -95,13 +128,110 @@ func (fsys *FileSystem) osCreate(ctx, path string) (stream.File, error) {
 		}
 	}
 
-	return nopCanceler{File: fd}, nil
+	return &atomicFile{File: fd, path: path}, nil
+}
+
+// Atomic marks the file system as AtomicFS. Files become visible only
+// after successful Close.
+func (fsys *FileSystem) Atomic() {}
+
+// temporary files are hidden next to the target, listings skip them
+const tempSuffix = ".partial"
+
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
+
+// temporary file, renamed to path on close
+type atomicFile struct {
+	*os.File
+	path   string
+	closed bool
+}
+
+// Stat describes the file being written by the name of target
+func (fd *atomicFile) Stat() (fs.FileInfo, error) {
+	fi, err := fd.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return atomicInfo{FileInfo: fi, name: filepath.Base(fd.path)}, nil
 }
 
-type nopCanceler struct{ *os.File }
+type atomicInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi atomicInfo) Name() string { return fi.name }
+
+func (fd *atomicFile) Write(p []byte) (int, error) {
+	n, err := fd.File.Write(p)
+	return n, noSpace(err)
+}
 
-// Cancel effect of file i/o
-func (nopCanceler) Cancel() error { return fmt.Errorf("not supported") }
+func (fd *atomicFile) WriteString(s string) (int, error) {
+	n, err := fd.File.WriteString(s)
+	return n, noSpace(err)
+}
+
+func (fd *atomicFile) ReadFrom(r io.Reader) (int64, error) {
+	n, err := fd.File.ReadFrom(r)
+	return n, noSpace(err)
+}
+
+func (fd *atomicFile) Close() error {
+	if fd.closed {
+		return nil
+	}
+	fd.closed = true
+
+	if err := fd.File.Close(); err != nil {
+		os.Remove(fd.File.Name())
+		return noSpace(err)
+	}
+
+	if err := os.Rename(fd.File.Name(), fd.path); err != nil {
+		os.Remove(fd.File.Name())
+		return &fs.PathError{
+			Op:   "close",
+			Path: fd.path,
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
+// ENOSPC is reported as stream.ErrNoSpace, keeping the path error
+func noSpace(err error) error {
+	if err == nil || !errors.Is(err, syscall.ENOSPC) {
+		return err
+	}
+
+	var e *fs.PathError
+	if errors.As(err, &e) {
+		return &fs.PathError{
+			Op:   e.Op,
+			Path: e.Path,
+			Err:  fmt.Errorf("%w: %w", stream.ErrNoSpace, e.Err),
+		}
+	}
+
+	return fmt.Errorf("%w: %w", stream.ErrNoSpace, err)
+}
+
+// Cancel effect of file i/o, the temporary file is discarded.
+func (fd *atomicFile) Cancel() error {
+	if fd.closed {
+		return nil
+	}
+	fd.closed = true
+
+	fd.File.Close()
+	return os.Remove(fd.File.Name())
+}
 
 // To open the file for reading use `Open` function giving the absolute path
 // starting with `/`, the returned file descriptor is a composite of
@@ -121,6 +251,23 @@ func (fsys *FileSystem) Stat(path string) (fs.FileInfo, error) {
 	return fsys.fs.Stat(trim(path))
 }
 
+// Exists reports if the file exists, it is false without error if not.
+func (fsys *FileSystem) Exists(path string) (bool, error) {
+	if err := stream.RequireValidFile("stat", path); err != nil {
+		return false, err
+	}
+
+	_, err := os.Stat(filepath.Join(fsys.Root, path))
+	switch {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 // Reads the named directory or path prefix.
 //
 // It assumes a directory if the path ends with `/`.
@@ -132,20 +279,58 @@ func (fsys *FileSystem) ReadDir(path string) ([]fs.DirEntry, error) {
 	}
 
 	if f, ok := fsys.fs.(fs.ReadDirFS); ok {
-		return f.ReadDir(trim(path))
+		seq, err := f.ReadDir(trim(path))
+		if err != nil {
+			return nil, err
+		}
+
+		// files being written are not observable
+		return slices.DeleteFunc(seq, func(e fs.DirEntry) bool {
+			return !e.IsDir() && isTempFile(e.Name())
+		}), nil
 	}
 
 	return nil, fmt.Errorf("invalid os.DirFS configuration")
 }
 
-// Glob returns the names of all files matching pattern.
+// Glob returns the names of all files matching pattern, it follows
+// path.Match syntax (e.g. "/the/*/key") and returns absolute paths.
+func (fsys *FileSystem) Glob(pattern string) ([]string, error) {
+	if len(pattern) == 0 || pattern[0] != '/' {
+		return nil, &fs.PathError{
+			Op:   "glob",
+			Path: pattern,
+			Err:  fs.ErrInvalid,
+		}
+	}
+
+	seq, err := fs.Glob(fsys.fs, pattern[1:])
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "glob",
+			Path: pattern,
+			Err:  err,
+		}
+	}
+
+	// files being written are not observable
+	seq = slices.DeleteFunc(seq, func(x string) bool { return isTempFile(path.Base(x)) })
+
+	for i, x := range seq {
+		seq[i] = "/" + x
+	}
+
+	return seq, nil
+}
+
+// GlobRegexp returns the names of all files matching pattern.
 //
 // It assumes a directory if the path ends with `/`.
 //
 // It return path relative to pattern for all found object.
 //
 // The pattern consists of path prefix Golang regex. Its are split by `|`.
-func (fsys *FileSystem) Glob(pattern string) ([]string, error) {
+func (fsys *FileSystem) GlobRegexp(pattern string) ([]string, error) {
 	var reg *regexp.Regexp
 	var err error
 
@@ -186,8 +371,34 @@ func (fsys *FileSystem) Remove(path string) error {
 	return os.Remove(file)
 }
 
+// RemoveDir removes the directory and all files under it. The root of
+// file system is kept, only its entries are removed.
+func (fsys *FileSystem) RemoveDir(path string) error {
+	if err := stream.RequireValidDir("remove", path); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(fsys.Root, path)
+	if dir != filepath.Clean(fsys.Root) {
+		return os.RemoveAll(dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Copy object from source location to the target.
-func (fsys *FileSystem) Copy(source, target string) (err error) {
+func (fsys *FileSystem) Copy(source, target string) error {
 	if err := stream.RequireValidFile("copy", source); err != nil {
 		return err
 	}
@@ -206,12 +417,53 @@ func (fsys *FileSystem) Copy(source, target string) (err error) {
 	if err != nil {
 		return err
 	}
-	defer func() { err = w.Close() }()
 
+	// partial copy is discarded, the target is published on success only
 	if _, err := io.Copy(w, r); err != nil {
+		w.Cancel()
 		return &fs.PathError{Op: "copy", Path: target, Err: err}
 	}
 
+	return w.Close()
+}
+
+// Rename moves the file to the target path using os.Rename, missing
+// directories of the target are created.
+func (fsys *FileSystem) Rename(source, target string) error {
+	if err := stream.RequireValidFile("rename", source); err != nil {
+		return err
+	}
+
+	if err := stream.RequireValidFile("rename", target); err != nil {
+		return err
+	}
+
+	file := filepath.Join(fsys.Root, target)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		if errors.Is(err, syscall.ENOTDIR) {
+			err = fmt.Errorf("%w: %w", stream.ErrNotDir, err)
+		}
+
+		return noSpace(&fs.PathError{
+			Op:   "rename",
+			Path: target,
+			Err:  err,
+		})
+	}
+
+	if err := os.Rename(filepath.Join(fsys.Root, source), file); err != nil {
+		var e *os.LinkError
+		if errors.As(err, &e) {
+			err = e.Err
+		}
+
+		return &fs.PathError{
+			Op:   "rename",
+			Path: source,
+			Err:  err,
+		}
+	}
+
 	return nil
 }
 
@@ -223,14 +475,6 @@ func (fsys *FileSystem) Wait(path string, timeout time.Duration) error {
 
 	t := time.Now()
 	for {
-		if time.Since(t) >= timeout {
-			return &fs.PathError{
-				Op:   "wait",
-				Path: path,
-				Err:  fmt.Errorf("timeout"),
-			}
-		}
-
 		_, err := fsys.Stat(path)
 		switch {
 		case err == nil:
@@ -239,6 +483,14 @@ func (fsys *FileSystem) Wait(path string, timeout time.Duration) error {
 			return err
 		}
 
+		if time.Since(t) >= timeout {
+			return &fs.PathError{
+				Op:   "wait",
+				Path: path,
+				Err:  fmt.Errorf("timeout"),
+			}
+		}
+
 		time.Sleep(2 * time.Second)
 	}
 }
@@ -14,12 +14,16 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/fogfish/it/v2"
+	"github.com/fogfish/stream"
 	"github.com/fogfish/stream/lfs"
+	"github.com/fogfish/stream/streamtest"
 )
 
 var (
@@ -44,6 +48,20 @@ func TestNew(t *testing.T) {
 
 }
 
+func TestUnwrap(t *testing.T) {
+	s3fs, err := lfs.NewTempFS("", "lfs")
+	it.Then(t).Should(
+		it.Nil(err),
+		it.Nil(createFile(s3fs)),
+	)
+
+	buf, err := fs.ReadFile(s3fs.Unwrap(), file[1:])
+	it.Then(t).Should(
+		it.Nil(err),
+		it.Equal(string(buf), content),
+	)
+}
+
 func TestReadWrite(t *testing.T) {
 	t.Run("File/Read", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
@@ -129,6 +147,92 @@ func TestReadWrite(t *testing.T) {
 		it.Then(t).Must(it.Nil(err))
 	})
 
+	t.Run("File/Write/Atomic", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		fi, err := s3fs.Stat(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(fi.Size(), size),
+		)
+	})
+
+	t.Run("File/Write/Atomic/Listing", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+		defer fd.Close()
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		fi, err := fd.Stat()
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(fi.Name(), "key"),
+		)
+
+		seq, err := s3fs.ReadDir("/the/example/")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(seq), 0),
+		)
+
+		glob, err := fs.Glob(s3fs, "/the/example/*")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(glob), 0),
+		)
+
+		walk := make([]string, 0)
+		err = fs.WalkDir(s3fs, "/the/example/", func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				walk = append(walk, path)
+			}
+			return err
+		})
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(walk), 0),
+		)
+	})
+
+	t.Run("File/Write/Cancel", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		fd, err := s3fs.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Nil(fd.Cancel()),
+			it.Nil(fd.Close()),
+		)
+
+		seq, err := s3fs.ReadDir("/the/example/")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(seq), 0),
+		)
+	})
+
 	t.Run("File/Write/Error/Dir", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
 		it.Then(t).Should(
@@ -151,6 +255,20 @@ func TestReadWrite(t *testing.T) {
 		it.Then(t).ShouldNot(it.Nil(err))
 	})
 
+	t.Run("File/Write/Error/NotDir", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		_, err = s3fs.Create(file+"/some", nil)
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrNotDir)),
+			it.True(errors.Is(err, fs.ErrInvalid)),
+		)
+	})
+
 	t.Run("File/Write/Error/InvalidPath", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
 		it.Then(t).Should(it.Nil(err))
@@ -168,6 +286,24 @@ func TestReadWrite(t *testing.T) {
 			it.Error(s3fs.Create(dir, nil)),
 		)
 	})
+
+	t.Run("File/Write/Error/NoSpace", func(t *testing.T) {
+		fd, done, err := lfs.OpenDevFull()
+		if err != nil {
+			t.Skip("/dev/full is not available")
+		}
+		defer done()
+
+		_, err = fd.Write([]byte(content))
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrNoSpace)),
+		)
+
+		_, err = io.Copy(fd, strings.NewReader(content))
+		it.Then(t).Should(
+			it.True(errors.Is(err, stream.ErrNoSpace)),
+		)
+	})
 }
 
 func TestWalk(t *testing.T) {
@@ -226,14 +362,42 @@ func TestWalk(t *testing.T) {
 			it.Error(os.Create(filepath.Join(s3fs.Root, dir, "3"))),
 		)
 
-		seq, err := s3fs.Glob(dir)
+		seq, err := fs.Glob(s3fs, "/the/*/dir/[12]")
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(seq).Equal(dir+"1", dir+"2"),
+		)
+	})
+
+	t.Run("Glob/Error", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Should(
+			it.Error(s3fs.Glob(dir + "[")),
+		)
+	})
+
+	t.Run("GlobRegexp", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Nil(os.MkdirAll(filepath.Join(s3fs.Root, dir), 0755)),
+		)
+		it.Then(t).ShouldNot(
+			it.Error(os.Create(filepath.Join(s3fs.Root, dir, "1"))),
+			it.Error(os.Create(filepath.Join(s3fs.Root, dir, "2"))),
+			it.Error(os.Create(filepath.Join(s3fs.Root, dir, "3"))),
+		)
+
+		seq, err := s3fs.GlobRegexp(dir)
 		it.Then(t).Must(it.Nil(err))
 		it.Then(t).Should(
 			it.Seq(seq).Equal("1", "2", "3"),
 		)
 	})
 
-	t.Run("GlobWithPattern", func(t *testing.T) {
+	t.Run("GlobRegexpWithPattern", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
 		it.Then(t).Must(
 			it.Nil(err),
@@ -245,19 +409,19 @@ func TestWalk(t *testing.T) {
 			it.Error(os.Create(filepath.Join(s3fs.Root, dir, "3"))),
 		)
 
-		seq, err := s3fs.Glob(dir + "|2")
+		seq, err := s3fs.GlobRegexp(dir + "|2")
 		it.Then(t).Must(it.Nil(err))
 		it.Then(t).Should(
 			it.Seq(seq).Equal("2"),
 		)
 	})
 
-	t.Run("GlobWithPattern/Error", func(t *testing.T) {
+	t.Run("GlobRegexpWithPattern/Error", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
 		it.Then(t).Must(it.Nil(err))
 
 		it.Then(t).Should(
-			it.Error(s3fs.Glob(dir + "|\\")),
+			it.Error(s3fs.GlobRegexp(dir + "|\\")),
 		)
 	})
 
@@ -400,6 +564,28 @@ func TestCopy(t *testing.T) {
 		)
 	})
 
+	t.Run("Copy/Error/Read", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		// the directory is opened as file, its read fails
+		target := filepath.Join(s3fs.Root, "test/file")
+		err = s3fs.Copy(filepath.Dir(file), target)
+		it.Then(t).ShouldNot(it.Nil(err))
+
+		_, err = os.Stat(target)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+
+		seq, err := os.ReadDir(filepath.Dir(target))
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(len(seq), 0),
+		)
+	})
+
 	t.Run("Copy/Error/Target", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
 		it.Then(t).Should(
@@ -438,6 +624,48 @@ func TestCopy(t *testing.T) {
 	})
 }
 
+func TestRename(t *testing.T) {
+	t.Run("Rename", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		err = s3fs.Rename(file, "/the/other/key")
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := fs.ReadFile(s3fs, "/the/other/key")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Error/NotFound", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		err = s3fs.Rename(file, "/the/other/key")
+		var e *fs.PathError
+		it.Then(t).Should(
+			it.True(errors.Is(err, fs.ErrNotExist)),
+			it.True(errors.As(err, &e)),
+		)
+	})
+
+	t.Run("Error/InvalidPath", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		err = s3fs.Rename(file, dir)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
 func TestWait(t *testing.T) {
 	t.Run("Wait", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
@@ -450,6 +678,21 @@ func TestWait(t *testing.T) {
 		it.Then(t).Must(it.Nil(err))
 	})
 
+	t.Run("Wait/Exists", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		t0 := time.Now()
+		err = s3fs.Wait(file, 0)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Less(time.Since(t0), 100*time.Millisecond),
+		)
+	})
+
 	t.Run("Wait/Created", func(t *testing.T) {
 		s3fs, err := lfs.NewTempFS("", "lfs")
 		it.Then(t).Must(
@@ -589,6 +832,221 @@ func TestStat(t *testing.T) {
 	})
 }
 
+func TestSync(t *testing.T) {
+	setup := func(t *testing.T) (*lfs.FileSystem, *lfs.FileSystem) {
+		src, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+		dst, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+
+		it.Then(t).Must(
+			it.Nil(createFileAt(src, dir+"a", content)),
+			it.Nil(createFileAt(src, dir+"sub/b", content)),
+			it.Nil(createFileAt(dst, dir+"sub/b", "Hello")),
+			it.Nil(createFileAt(dst, dir+"c", content)),
+		)
+
+		return src, dst
+	}
+
+	t.Run("Sync", func(t *testing.T) {
+		src, dst := setup(t)
+
+		report, err := stream.Sync(src, dst, dir, stream.WithDeleteExtraneous(true))
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(report.Copied).Equal(dir+"a", dir+"sub/b"),
+			it.Seq(report.Deleted).Equal(dir+"c"),
+		)
+
+		buf, err := fs.ReadFile(dst, dir+"sub/b")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+
+		_, err = dst.Stat(dir + "c")
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+
+		report, err = stream.Sync(src, dst, dir)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(len(report.Copied), 0),
+			it.Seq(report.Skipped).Equal(dir+"a", dir+"sub/b"),
+		)
+	})
+
+	t.Run("Sync/DryRun", func(t *testing.T) {
+		src, dst := setup(t)
+
+		report, err := stream.Sync(src, dst, dir,
+			stream.WithDryRun(true),
+			stream.WithDeleteExtraneous(true),
+		)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Seq(report.Copied).Equal(dir+"a", dir+"sub/b"),
+			it.Seq(report.Deleted).Equal(dir+"c"),
+		)
+
+		_, err = dst.Stat(dir + "a")
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+
+		_, err = dst.Stat(dir + "c")
+		it.Then(t).Should(it.Nil(err))
+	})
+
+	t.Run("Sync/Error/InvalidPath", func(t *testing.T) {
+		src, dst := setup(t)
+
+		_, err := stream.Sync(src, dst, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+}
+
+func TestCopyAcross(t *testing.T) {
+	t.Run("CopyAcross", func(t *testing.T) {
+		src := fstest.MapFS{
+			"the/example/key": &fstest.MapFile{Data: []byte(content)},
+		}
+		dst, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+
+		err = stream.CopyAcross(src, "the/example/key", dst, dir+"key")
+		it.Then(t).Must(it.Nil(err))
+
+		buf, err := fs.ReadFile(dst, dir+"key")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+	})
+
+	t.Run("CopyAcross/Error/NotFound", func(t *testing.T) {
+		src, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+		dst, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+
+		err = stream.CopyAcross(src, file, dst, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("CopyAcross/Error/InvalidPath", func(t *testing.T) {
+		src, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Nil(createFile(src)),
+		)
+		dst, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Must(it.Nil(err))
+
+		err = stream.CopyAcross(src, file, dst, dir)
+		it.Then(t).ShouldNot(it.Nil(err))
+
+		_, err = dst.Stat(dir)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+}
+
+func TestOpenMmap(t *testing.T) {
+	t.Run("OpenMmap", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		fd, err := s3fs.OpenMmap(file)
+		it.Then(t).Must(it.Nil(err))
+
+		buf := make([]byte, 5)
+		n, err := fd.ReadAt(buf, 6)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(n, 5),
+			it.Equal(string(buf), content[6:11]),
+			it.Equal(fd.Len(), len(content)),
+		)
+
+		n, err = fd.ReadAt(buf, 10)
+		it.Then(t).Should(
+			it.Equal(err, io.EOF),
+			it.Equal(string(buf[:n]), content[10:]),
+		)
+
+		it.Then(t).Must(it.Nil(fd.Close()))
+
+		_, err = fd.ReadAt(buf, 0)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrClosed)))
+	})
+
+	t.Run("OpenMmap/Empty", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFileAt(s3fs, file, "")),
+		)
+
+		fd, err := s3fs.OpenMmap(file)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = fd.ReadAt(make([]byte, 5), 0)
+		it.Then(t).Should(
+			it.Equal(err, io.EOF),
+			it.Nil(fd.Close()),
+		)
+	})
+
+	t.Run("OpenMmap/Error/NotFound", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.OpenMmap(file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("OpenMmap/Error/Dir", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		_, err = s3fs.OpenMmap(filepath.Dir(file))
+		it.Then(t).Should(it.Fail(func() error { return err }))
+	})
+}
+
+func TestConformance(t *testing.T) {
+	streamtest.TestFS(t,
+		func(t *testing.T) stream.CreateFS[struct{}] {
+			fsys, err := lfs.NewTempFS("", "lfs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return fsys
+		},
+		// lfs copies to the path of local file system
+		streamtest.WithCopyTarget(func(fsys stream.CreateFS[struct{}], path string) string {
+			return filepath.Join(fsys.(*lfs.FileSystem).Root, path)
+		}),
+	)
+}
+
+func createFileAt(fsys *lfs.FileSystem, path, content string) error {
+	fd, err := fsys.Create(path, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fd.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	return fd.Close()
+}
+
 func createFile(fsys *lfs.FileSystem) error {
 	fd, err := fsys.Create(file, nil)
 	if err != nil {
@@ -607,3 +1065,83 @@ func createFile(fsys *lfs.FileSystem) error {
 
 	return nil
 }
+
+func TestRemoveDir(t *testing.T) {
+	t.Run("RemoveDir", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		err = s3fs.RemoveDir("/the/")
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = s3fs.Stat(file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("RemoveDir/Root", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		err = s3fs.RemoveDir("/")
+		it.Then(t).Must(it.Nil(err))
+
+		root, err := os.Stat(s3fs.Root)
+		it.Then(t).Must(it.Nil(err))
+
+		seq, err := s3fs.ReadDir("/")
+		it.Then(t).Should(
+			it.True(root.IsDir()),
+			it.Nil(err),
+			it.Equal(len(seq), 0),
+		)
+	})
+
+	t.Run("Error/NotDir", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		err = s3fs.RemoveDir(file)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+}
+
+func TestExists(t *testing.T) {
+	t.Run("Exists", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Nil(createFile(s3fs)),
+		)
+
+		has, err := s3fs.Exists(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.True(has),
+		)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		has, err := s3fs.Exists(file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.True(!has),
+		)
+	})
+
+	t.Run("Error/Dir", func(t *testing.T) {
+		s3fs, err := lfs.NewTempFS("", "lfs")
+		it.Then(t).Should(it.Nil(err))
+
+		_, err = s3fs.Exists("/the/example/")
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+}
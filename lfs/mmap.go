@@ -0,0 +1,122 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package lfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fogfish/stream"
+)
+
+// OpenMmap maps the file into memory for zero-copy random access (e.g.
+// browsing archives at local cache). The file is mapped read-only and
+// shared, modifications of the file are visible through the mapping,
+// truncation of the mapped file causes SIGBUS on access. Close unmaps the
+// file, it must not be concurrent with ReadAt. Supported on Linux, macOS and
+// BSDs, other platforms fail with errors.ErrUnsupported.
+func (fsys *FileSystem) OpenMmap(path string) (stream.MmapFile, error) {
+	if err := stream.RequireValidFile("mmap", path); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.Open(filepath.Join(fsys.Root, path))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	fi, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return nil, &fs.PathError{
+			Op:   "mmap",
+			Path: path,
+			Err:  syscall.EISDIR,
+		}
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		// zero length mapping is not permitted
+		return &mmapFile{}, nil
+	}
+
+	if int64(int(size)) != size {
+		return nil, &fs.PathError{
+			Op:   "mmap",
+			Path: path,
+			Err:  fmt.Errorf("%w: %d bytes", stream.ErrObjectTooLarge, size),
+		}
+	}
+
+	data, err := syscall.Mmap(int(fd.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "mmap",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	return &mmapFile{data: data}, nil
+}
+
+// memory mapped file
+type mmapFile struct {
+	data   []byte
+	closed bool
+}
+
+func (fd *mmapFile) Len() int { return len(fd.data) }
+
+func (fd *mmapFile) ReadAt(p []byte, off int64) (int, error) {
+	if fd.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if off < 0 {
+		return 0, fmt.Errorf("%w: negative offset %d", fs.ErrInvalid, off)
+	}
+
+	if off >= int64(len(fd.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, fd.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (fd *mmapFile) Close() error {
+	if fd.closed {
+		return nil
+	}
+	fd.closed = true
+
+	if fd.data == nil {
+		return nil
+	}
+
+	data := fd.data
+	fd.data = nil
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,28 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package lfs
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/fogfish/stream"
+)
+
+// OpenMmap is not supported on the platform, it fails with
+// errors.ErrUnsupported.
+func (fsys *FileSystem) OpenMmap(path string) (stream.MmapFile, error) {
+	return nil, &fs.PathError{
+		Op:   "mmap",
+		Path: path,
+		Err:  errors.ErrUnsupported,
+	}
+}
@@ -0,0 +1,161 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MetricsSink observes latency and outcome of each S3 operation (e.g.
+// "HeadObject", "GetObject", "Upload"). It is an adapter to the metrics
+// library of the application (e.g. Prometheus counters and histograms).
+// The sink is called concurrently.
+type MetricsSink interface {
+	ObserveOp(op string, dur time.Duration, err error)
+}
+
+// NoopMetrics discards all observations, it is the default sink.
+type NoopMetrics struct{}
+
+func (NoopMetrics) ObserveOp(string, time.Duration, error) {}
+
+// Metrics of the operation collected by MemoryMetrics
+type OpMetrics struct {
+	Count   int
+	Errors  int
+	Latency time.Duration
+}
+
+// MemoryMetrics is in-memory sink, aggregating count, errors and
+// total latency per operation. It is intended for testing.
+type MemoryMetrics struct {
+	mu  sync.Mutex
+	ops map[string]OpMetrics
+}
+
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{ops: make(map[string]OpMetrics)}
+}
+
+func (m *MemoryMetrics) ObserveOp(op string, dur time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v := m.ops[op]
+	v.Count++
+	v.Latency += dur
+	if err != nil {
+		v.Errors++
+	}
+	m.ops[op] = v
+}
+
+// Metrics of the operation
+func (m *MemoryMetrics) Get(op string) OpMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ops[op]
+}
+
+//-----------------------------------------------------------------------------
+
+// S3 client observing each operation
+type meteredS3 struct {
+	api  S3
+	sink MetricsSink
+}
+
+func (m meteredS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	t := time.Now()
+	val, err := m.api.HeadObject(ctx, params, optFns...)
+	m.sink.ObserveOp("HeadObject", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	t := time.Now()
+	val, err := m.api.GetObject(ctx, params, optFns...)
+	m.sink.ObserveOp("GetObject", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	t := time.Now()
+	val, err := m.api.PutObject(ctx, params, optFns...)
+	m.sink.ObserveOp("PutObject", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	t := time.Now()
+	val, err := m.api.ListObjectsV2(ctx, params, optFns...)
+	m.sink.ObserveOp("ListObjectsV2", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	t := time.Now()
+	val, err := m.api.DeleteObject(ctx, params, optFns...)
+	m.sink.ObserveOp("DeleteObject", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	t := time.Now()
+	val, err := m.api.DeleteObjects(ctx, params, optFns...)
+	m.sink.ObserveOp("DeleteObjects", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	t := time.Now()
+	val, err := m.api.CopyObject(ctx, params, optFns...)
+	m.sink.ObserveOp("CopyObject", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	t := time.Now()
+	val, err := m.api.ListMultipartUploads(ctx, params, optFns...)
+	m.sink.ObserveOp("ListMultipartUploads", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	t := time.Now()
+	val, err := m.api.GetObjectTagging(ctx, params, optFns...)
+	m.sink.ObserveOp("GetObjectTagging", time.Since(t), err)
+	return val, err
+}
+
+func (m meteredS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	t := time.Now()
+	val, err := m.api.AbortMultipartUpload(ctx, params, optFns...)
+	m.sink.ObserveOp("AbortMultipartUpload", time.Since(t), err)
+	return val, err
+}
+
+// S3 upload client observing each upload
+type meteredS3Upload struct {
+	upload S3Upload
+	sink   MetricsSink
+}
+
+func (m meteredS3Upload) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	t := time.Now()
+	val, err := m.upload.Upload(ctx, input, opts...)
+	m.sink.ObserveOp("Upload", time.Since(t), err)
+	return val, err
+}
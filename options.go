@@ -10,12 +10,17 @@ package stream
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/fogfish/opts"
 )
 
@@ -26,9 +31,38 @@ type Opts struct {
 	api          S3
 	upload       S3Upload
 	signer       S3Signer
+	waiter       S3Waiter
 	timeout      time.Duration
 	ttlSignedUrl time.Duration
 	lslimit      int32
+	logger       *slog.Logger
+	normalize    bool
+	lenientDir   bool
+	smallWrites  int64
+	defaultMeta  map[string]string
+	metaDelim    string
+	userAgent    string
+	dirAggregate bool
+	ctxMeta      map[string]any
+	putHook      func(*s3.PutObjectInput)
+	bucketOwner  string
+	maxSize      int64
+	regions      map[string]string
+	metrics      MetricsSink
+	decompress   map[string]func(io.Reader) (io.Reader, error)
+	region       string
+	notFound     func(error) bool
+	partSize     int64
+	concurrency  int
+	maxDepth     int
+	autoPreSign  bool
+	dirMarkers   bool
+	uploadPart   int64
+	uploadConc   int
+	sse          types.ServerSideEncryption
+	sseKMSKeyID  string
+	checksum     bool
+	config       *aws.Config
 }
 
 func (c *Opts) checkRequired() error {
@@ -38,6 +72,26 @@ func (c *Opts) checkRequired() error {
 	)
 }
 
+func (c *Opts) checkUpload() error {
+	if c.uploadPart != 0 && c.uploadPart < manager.MinUploadPartSize {
+		return fmt.Errorf("upload part size %d is less than %d bytes required by S3", c.uploadPart, manager.MinUploadPartSize)
+	}
+
+	if c.uploadConc < 0 {
+		return fmt.Errorf("upload concurrency %d is negative", c.uploadConc)
+	}
+
+	return nil
+}
+
+func (c *Opts) checkDownload() error {
+	if c.partSize > 0 && c.checksum {
+		return fmt.Errorf("checksum validation is not supported by parallel download")
+	}
+
+	return nil
+}
+
 var (
 	// Set S3 client for the file system
 	WithS3 = opts.ForType[Opts, S3]()
@@ -48,6 +102,10 @@ var (
 	// Set S3 url signer client for the file system
 	WithS3Signer = opts.ForType[Opts, S3Signer]()
 
+	// Set S3 waiter for the file system, it is used by Wait. By default,
+	// s3.ObjectExistsWaiter of the S3 client is used.
+	WithS3Waiter = opts.ForType[Opts, S3Waiter]()
+
 	// Use aws.Config as base config for S3, S3 upload and S3 url signer clients
 	WithConfig = opts.FMap(optsFromConfig)
 
@@ -67,13 +125,181 @@ var (
 
 	// Set the number of keys to be read from S3 while walking through "dir"
 	WithListingLimit = opts.ForName[Opts, int32]("lslimit")
+
+	// Set the logger for debugging S3 requests. The file system logs bucket,
+	// key and operation of every S3 call at debug level. No logging by default.
+	WithDebugLogger = opts.ForType[Opts, *slog.Logger]()
+
+	// Normalize paths before validation, collapsing repeated slashes and
+	// ensuring the leading "/". With normalization on, both "file.txt" and
+	// "/file.txt" address the same object.
+	WithPathNormalization = opts.ForName[Opts, bool]("normalize")
+
+	// Accept directories without trailing "/". ReadDir appends the missing "/"
+	// and Stat probes the prefix if no object is found at the path, making
+	// fs.WalkDir(fsys, "/the/example") possible at the cost of a listing.
+	WithLenientDir = opts.ForName[Opts, bool]("lenientDir")
+
+	// Buffer writes up to the threshold (bytes) in memory. Objects closed
+	// before exceeding the threshold are written with a single PutObject call,
+	// larger objects fall through to streaming multipart upload.
+	WithBufferSmallWrites = opts.ForName[Opts, int64]("smallWrites")
+
+	// Set default metadata written with every object. Metadata of the object
+	// takes precedence over defaults.
+	WithDefaultMetadata = opts.ForName[Opts, map[string]string]("defaultMeta")
+
+	// Set the delimiter joining keys of nested metadata struct, default "-".
+	// The field `Provenance struct{ Source string }` is mapped to the
+	// metadata key "provenance-source".
+	WithMetadataDelimiter = opts.ForName[Opts, string]("metaDelim")
+
+	// Append the application name to the User-Agent of S3 requests, keeping
+	// the SDK's own user agent. It helps to attribute the traffic in access
+	// logs and CloudTrail. Applies to clients built from aws.Config.
+	WithUserAgent = opts.ForName[Opts, string]("userAgent")
+
+	// Aggregate directory Stat: the prefix is listed to report the total size
+	// and the latest modification time of objects under it. Every Stat of the
+	// directory costs a full (paginated) listing of the prefix, which is slow
+	// and expensive for large prefixes. By default, directory Stat is
+	// a synthetic zero-size entry without any I/O.
+	WithDirStatAggregate = opts.ForName[Opts, bool]("dirAggregate")
+
+	// Customize PutObject request (e.g. GrantRead, WebsiteRedirectLocation)
+	// for the fields not supported by the file system. The hook runs last,
+	// after metadata is encoded, and it can override any field of request.
+	WithPutObjectHook = opts.ForName[Opts, func(*s3.PutObjectInput)]("putHook")
+
+	// Set the account id expected to own the bucket. Every S3 request fails
+	// if the bucket is owned by another account, protecting against requests
+	// to the bucket re-created by a third party under the same name.
+	WithExpectedBucketOwner = opts.ForName[Opts, string]("bucketOwner")
+
+	// Set the size limit (bytes) of objects being read. Reading of larger
+	// objects fails with ErrObjectTooLarge before the body is streamed.
+	WithMaxObjectSize = opts.ForName[Opts, int64]("maxSize")
+
+	// Route requests to the region by the key prefix (e.g. "eu/" to
+	// "eu-west-1"), the longest matching prefix wins. Keys without matching
+	// prefix use the region of the client. The region (and endpoint) is
+	// resolved per request by the S3 client.
+	WithRegionForPrefix = opts.ForName[Opts, map[string]string]("regions")
+
+	// Set the sink observing latency and outcome of each S3 operation,
+	// see MetricsSink. No metrics are collected by default.
+	WithMetrics = opts.ForType[Opts, MetricsSink]()
+
+	// Decompress objects by the suffix of the path (e.g. ".gz", ".zst"), the
+	// body of the object is wrapped with the decompressor of the longest
+	// matching suffix. Decompressors are supplied by the application
+	// (e.g. gzip.NewReader). Ranged reads (OpenRange) are not decompressed,
+	// Stat reports the size of the compressed object.
+	WithDecompressBySuffix = opts.ForName[Opts, map[string]func(io.Reader) (io.Reader, error)]("decompress")
+
+	// Set the region used only if none is resolved from aws.Config (e.g.
+	// AWS_REGION, shared config), typical for containers in minimal
+	// environments. New fails if the region cannot be determined.
+	WithDefaultRegion = opts.ForName[Opts, string]("region")
+
+	// Detect not found errors of S3-compatible stores (e.g. MinIO, Ceph)
+	// returning custom error codes. Errors with codes NoSuchKey, NotFound
+	// or HTTP status 404 are always reported as fs.ErrNotExist.
+	WithNotFoundDetector = opts.ForName[Opts, func(error) bool]("notFound")
+
+	// Limit the depth of recursive listing of prefixes (see ListPrefixes),
+	// the depth 1 reports only immediate subdirectories. Unlimited by default.
+	WithMaxDepth = opts.ForName[Opts, int]("maxDepth")
+
+	// Populate PreSignedUrl of metadata on Stat and Open (default true).
+	// Disable it to avoid signing on the hot read path, use PreSignGetURL
+	// to obtain the url explicitly.
+	WithAutoPreSign = opts.ForName[Opts, bool]("autoPreSign")
+
+	// Maintain zero-byte directory marker objects ("prefix/") expected by
+	// some tools (e.g. Hadoop connectors). Create writes missing markers of
+	// parent directories, Remove deletes markers of directories left empty.
+	// Each write and remove costs extra requests. Disabled by default.
+	WithDirectoryMarkers = opts.ForName[Opts, bool]("dirMarkers")
+
+	// Set the part size (bytes) of multipart upload, it is at least 5 MiB
+	// required by S3. Larger parts reduce the number of requests for multi-GB
+	// objects at the cost of memory (part size x concurrency). Applies to
+	// the uploader built from aws.Config.
+	WithUploadPartSize = opts.ForName[Opts, int64]("uploadPart")
+
+	// Set the number of parts uploaded concurrently by multipart upload.
+	// Applies to the uploader built from aws.Config.
+	WithUploadConcurrency = opts.ForName[Opts, int]("uploadConc")
+
+	// Request server-side encryption of written objects (e.g. types.
+	// ServerSideEncryptionAes256 or types.ServerSideEncryptionAwsKms),
+	// including copies made by Copy, Rename and UpdateMeta. It is required by
+	// buckets rejecting unencrypted puts. Objects are encrypted by the
+	// bucket's default policy if not set.
+	WithServerSideEncryption = opts.ForName[Opts, types.ServerSideEncryption]("sse")
+
+	// Set the KMS key id for types.ServerSideEncryptionAwsKms encryption of
+	// written objects. The AWS managed key is used if not set.
+	WithSSEKMSKeyID = opts.ForName[Opts, string]("sseKMSKeyID")
+
+	// Verify the integrity of read objects against the checksum stored by S3
+	// (SHA256, SHA1, CRC32C or CRC32). The checksum is computed as bytes flow
+	// through Read, Close fails with ErrChecksumMismatch if it differs. It
+	// requires reading the full body, objects closed before the end of body,
+	// ranged reads and objects without (or with composite multipart)
+	// checksum are not verified. It cannot be combined with
+	// WithParallelDownload.
+	WithChecksumValidation = opts.ForName[Opts, bool]("checksum")
 )
 
+// Ranged read configuration options, see FileSystem.OpenRange
+type ReadOption = opts.Option[ReadOpts]
+
+// Ranged read configuration options
+type ReadOpts struct {
+	ifRange string
+}
+
+// Read the range only if the object matches the ETag, otherwise the read
+// fails with ErrRangeIgnored. S3 does not support If-Range header, the
+// condition is evaluated using If-Match together with Range.
+var IfRange = opts.ForName[ReadOpts, string]("ifRange")
+
+// Inject the value of the context key into the metadata key of written
+// objects (e.g. request id for correlation), use CreateCtx to supply the
+// context. Metadata of the object takes precedence over context values.
+func WithContextMetadataKey(metaKey string, ctxKey any) Option {
+	return opts.From(func(c *Opts) error {
+		if c.ctxMeta == nil {
+			c.ctxMeta = make(map[string]any)
+		}
+		c.ctxMeta[metaKey] = ctxKey
+		return nil
+	})()
+}
+
+// Download objects larger than part size (bytes) with parallel ranged
+// requests (manager.Downloader). The first part probes the size of object,
+// smaller objects are read from it as-is, larger objects are spilled into
+// temporary file before the first read. The option cannot be combined with
+// WithChecksumValidation, ranged parts do not carry the object's checksum.
+func WithParallelDownload(partSize int64, concurrency int) Option {
+	return opts.From(func(c *Opts) error {
+		c.partSize = partSize
+		c.concurrency = concurrency
+		return nil
+	})()
+}
+
 func optsDefault() Opts {
 	return Opts{
 		timeout:      120 * time.Second,
 		ttlSignedUrl: 5 * time.Minute,
 		lslimit:      1000,
+		metaDelim:    "-",
+		metrics:      NoopMetrics{},
+		autoPreSign:  true,
 	}
 }
 
@@ -98,18 +324,69 @@ func optsFromRegion(c *Opts, region string) error {
 }
 
 func optsFromConfig(c *Opts, cfg aws.Config) error {
-	api := s3.NewFromConfig(cfg)
+	c.config = &cfg
+	return nil
+}
+
+// clients are built once all options are applied, making the options
+// that customize clients (e.g. WithUserAgent) order independent.
+func optsClients(c *Opts) error {
+	if c.config == nil {
+		return nil
+	}
+
+	if c.config.Region == "" {
+		c.config.Region = c.region
+	}
+
+	if c.config.Region == "" {
+		return fmt.Errorf("region is not defined, use WithRegion, WithDefaultRegion or AWS_REGION")
+	}
+
+	api := s3.NewFromConfig(*c.config, c.s3Options)
 
 	if c.api == nil {
 		c.api = api
 	}
 
 	if c.upload == nil {
-		c.upload = manager.NewUploader(api)
+		c.upload = manager.NewUploader(api, c.uploaderOptions)
 	}
 
 	if c.signer == nil {
 		c.signer = s3.NewPresignClient(api)
 	}
+
 	return nil
 }
+
+// clients are instrumented with metrics sink, unless it is no-op
+func optsMetrics(c *Opts) {
+	if _, noop := c.metrics.(NoopMetrics); noop || c.metrics == nil {
+		return
+	}
+
+	if c.api != nil {
+		c.api = meteredS3{api: c.api, sink: c.metrics}
+	}
+
+	if c.upload != nil {
+		c.upload = meteredS3Upload{upload: c.upload, sink: c.metrics}
+	}
+}
+
+func (c *Opts) uploaderOptions(u *manager.Uploader) {
+	if c.uploadPart > 0 {
+		u.PartSize = c.uploadPart
+	}
+
+	if c.uploadConc > 0 {
+		u.Concurrency = c.uploadConc
+	}
+}
+
+func (c *Opts) s3Options(o *s3.Options) {
+	if c.userAgent != "" {
+		o.APIOptions = append(o.APIOptions, awsmiddleware.AddUserAgentKey(c.userAgent))
+	}
+}
@@ -0,0 +1,215 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PreSignPost generates the POST policy for direct upload of the object
+// to the path from the browser. The policy restricts the object size up to
+// maxBytes and embeds metadata and tags encoded from attr. The policy is
+// signed for the region of the path (see WithRegionForPrefix) with
+// credentials of aws.Config, the file system must be configured with it
+// (e.g. WithConfig), file systems built with WithS3 client fail with
+// errors.ErrUnsupported. POST upload cannot enforce the bucket owner,
+// file systems configured with WithExpectedBucketOwner fail as well.
+func (fsys *FileSystem[T]) PreSignPost(path string, attr *T, ttl time.Duration, maxBytes int64) (*PostPolicy, error) {
+	path = fsys.canonical(path)
+	if err := RequireValidFile("presign", path); err != nil {
+		return nil, err
+	}
+
+	if fsys.config == nil || fsys.config.Credentials == nil {
+		return nil, &fs.PathError{
+			Op:   "presign",
+			Path: path,
+			Err:  fmt.Errorf("%w: aws.Config with credentials is required", errors.ErrUnsupported),
+		}
+	}
+
+	if len(fsys.bucketOwner) != 0 {
+		return nil, &fs.PathError{
+			Op:   "presign",
+			Path: path,
+			Err:  fmt.Errorf("%w: expected bucket owner is not enforced by POST upload", errors.ErrUnsupported),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(fsys.ctx, fsys.timeout)
+	defer cancel()
+
+	creds, err := fsys.config.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "presign",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	region := fsys.config.Region
+	if r := fsys.regionFor(s3Key(path)); len(r) != 0 {
+		region = r
+	}
+
+	fields, err := fsys.postMetadata(ctx, attr)
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "presign",
+			Path: path,
+			Err:  err,
+		}
+	}
+	fields["key"] = PathToKey(path)
+	fields["x-amz-algorithm"] = "AWS4-HMAC-SHA256"
+	fields["x-amz-credential"] = fmt.Sprintf("%s/%s/%s/s3/aws4_request", creds.AccessKeyID, date, region)
+	fields["x-amz-date"] = now.Format("20060102T150405Z")
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	conditions := []any{
+		map[string]string{"bucket": fsys.bucket},
+		[]any{"content-length-range", 0, maxBytes},
+	}
+	for key, val := range fields {
+		conditions = append(conditions, map[string]string{key: val})
+	}
+
+	policy, err := json.Marshal(map[string]any{
+		"expiration": now.Add(ttl).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   "presign",
+			Path: path,
+			Err:  err,
+		}
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(policy)
+	fields["policy"] = encoded
+	fields["x-amz-signature"] = hex.EncodeToString(
+		hmacSHA256(signingKey(creds.SecretAccessKey, date, region), encoded),
+	)
+
+	return &PostPolicy{URL: fsys.postUrl(region), Fields: fields}, nil
+}
+
+// encodes metadata of the object as form fields
+func (fsys *FileSystem[T]) postMetadata(ctx context.Context, attr *T) (map[string]string, error) {
+	req := &s3.PutObjectInput{Metadata: make(map[string]string)}
+	fsys.encodeMetadata(ctx, attr, req)
+
+	fields := map[string]string{}
+	for key, val := range map[string]*string{
+		"Cache-Control":       req.CacheControl,
+		"Content-Disposition": req.ContentDisposition,
+		"Content-Encoding":    req.ContentEncoding,
+		"Content-Language":    req.ContentLanguage,
+		"Content-Type":        req.ContentType,
+	} {
+		if val != nil {
+			fields[key] = aws.ToString(val)
+		}
+	}
+
+	if req.Expires != nil {
+		fields["Expires"] = req.Expires.UTC().Format(time.RFC1123)
+	}
+
+	if len(req.StorageClass) != 0 {
+		fields["x-amz-storage-class"] = string(req.StorageClass)
+	}
+
+	for key, val := range req.Metadata {
+		fields["x-amz-meta-"+key] = val
+	}
+
+	if req.Tagging != nil {
+		tagging, err := postTagging(aws.ToString(req.Tagging))
+		if err != nil {
+			return nil, err
+		}
+		fields["tagging"] = tagging
+	}
+
+	return fields, nil
+}
+
+// POST upload accepts tags as XML document instead of URL-encoded pairs
+func postTagging(tagging string) (string, error) {
+	tags, err := url.ParseQuery(tagging)
+	if err != nil {
+		return "", err
+	}
+
+	type tag struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	}
+
+	doc := struct {
+		XMLName xml.Name `xml:"Tagging"`
+		TagSet  []tag    `xml:"TagSet>Tag"`
+	}{}
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		doc.TagSet = append(doc.TagSet, tag{Key: key, Value: tags.Get(key)})
+	}
+
+	val, err := xml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+
+	return string(val), nil
+}
+
+func (fsys *FileSystem[T]) postUrl(region string) string {
+	if fsys.config.BaseEndpoint != nil {
+		return strings.TrimSuffix(aws.ToString(fsys.config.BaseEndpoint), "/") + "/" + fsys.bucket
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", fsys.bucket, region)
+}
+
+// derives AWS Signature Version 4 signing key
+func signingKey(secret, date, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
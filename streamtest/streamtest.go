@@ -0,0 +1,242 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+// Package streamtest implements conformance tests for file systems, which
+// implement stream interfaces (e.g. S3, local file system). The tests define
+// the behavior contract shared by all backends.
+package streamtest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/fogfish/it/v2"
+	"github.com/fogfish/stream"
+)
+
+const (
+	root    = "/streamtest/"
+	file    = root + "a.txt"
+	content = "Hello World!"
+)
+
+// Option of conformance tests
+type Option func(*config)
+
+type config struct {
+	copyTarget func(fsys stream.CreateFS[struct{}], path string) string
+}
+
+// WithCopyTarget maps the path of the file system to the target of Copy, its
+// form is specific to backend (e.g. s3://bucket/key for S3, the path of local
+// file system for lfs). Copy is skipped if the target is not defined.
+func WithCopyTarget(f func(fsys stream.CreateFS[struct{}], path string) string) Option {
+	return func(c *config) { c.copyTarget = f }
+}
+
+// TestFS runs conformance tests against the file system. The factory returns
+// an empty file system for each test, it fails the test if the file system
+// cannot be created. Optional interfaces (fs.GlobFS, stream.RemoveFS,
+// stream.RenameFS, stream.CopyFS) are tested if implemented.
+func TestFS(t *testing.T, factory func(t *testing.T) stream.CreateFS[struct{}], opts ...Option) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		fsys := factory(t)
+		it.Then(t).Must(it.Nil(writeFile(fsys, file, content)))
+
+		buf, err := fs.ReadFile(fsys, file)
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+
+		fi, err := fs.Stat(fsys, file)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Should(
+			it.Equal(fi.Size(), int64(len(content))),
+			it.Equal(fi.IsDir(), false),
+		)
+	})
+
+	t.Run("Create/Cancel", func(t *testing.T) {
+		fsys := factory(t)
+		fd, err := fsys.Create(file, nil)
+		it.Then(t).Must(it.Nil(err))
+
+		_, err = io.WriteString(fd, content)
+		it.Then(t).Must(it.Nil(err))
+		it.Then(t).Must(it.Nil(fd.Cancel()))
+
+		_, err = fs.Stat(fsys, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Create/Error/InvalidPath", func(t *testing.T) {
+		fsys := factory(t)
+		for _, path := range []string{"", "a.txt", root, "/a/../b", "/a//b"} {
+			_, err := fsys.Create(path, nil)
+			it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+		}
+	})
+
+	t.Run("Open/Error/NotFound", func(t *testing.T) {
+		fsys := factory(t)
+
+		_, err := fs.ReadFile(fsys, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Open/Error/InvalidPath", func(t *testing.T) {
+		fsys := factory(t)
+		for _, path := range []string{"", "a.txt", "/a/../b"} {
+			_, err := fsys.Open(path)
+			it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+		}
+	})
+
+	t.Run("Stat/Error/NotFound", func(t *testing.T) {
+		fsys := factory(t)
+
+		_, err := fs.Stat(fsys, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("ReadDir", func(t *testing.T) {
+		fsys := factory(t)
+		it.Then(t).Must(
+			it.Nil(writeFile(fsys, root+"a.txt", content)),
+			it.Nil(writeFile(fsys, root+"b.txt", content)),
+		)
+
+		seq, err := fs.ReadDir(fsys, root)
+		it.Then(t).Must(
+			it.Nil(err),
+			it.Equal(len(seq), 2),
+		)
+		it.Then(t).Should(
+			it.Equal(seq[0].Name(), "a.txt"),
+			it.Equal(seq[1].Name(), "b.txt"),
+		)
+	})
+
+	t.Run("ReadDir/Error/NotDir", func(t *testing.T) {
+		fsys := factory(t)
+
+		_, err := fs.ReadDir(fsys, root[:len(root)-1])
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+
+	t.Run("Glob", func(t *testing.T) {
+		fsys := factory(t)
+		if _, ok := fsys.(fs.GlobFS); !ok {
+			t.Skip("fs.GlobFS is not implemented")
+		}
+
+		it.Then(t).Must(
+			it.Nil(writeFile(fsys, root+"a.txt", content)),
+			it.Nil(writeFile(fsys, root+"b.txt", content)),
+			it.Nil(writeFile(fsys, root+"c.bin", content)),
+		)
+
+		seq, err := fs.Glob(fsys, root+"*.txt")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Seq(seq).Equal(root+"a.txt", root+"b.txt"),
+		)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		fsys := factory(t)
+		rfs, ok := fsys.(stream.RemoveFS)
+		if !ok {
+			t.Skip("stream.RemoveFS is not implemented")
+		}
+
+		it.Then(t).Must(it.Nil(writeFile(fsys, file, content)))
+		it.Then(t).Must(it.Nil(rfs.Remove(file)))
+
+		_, err := fs.Stat(fsys, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+
+		err = rfs.Remove(root)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrInvalid)))
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		fsys := factory(t)
+		rfs, ok := fsys.(stream.RenameFS)
+		if !ok {
+			t.Skip("stream.RenameFS is not implemented")
+		}
+
+		it.Then(t).Must(it.Nil(writeFile(fsys, file, content)))
+		it.Then(t).Must(it.Nil(rfs.Rename(file, root+"b.txt")))
+
+		buf, err := fs.ReadFile(fsys, root+"b.txt")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+
+		_, err = fs.Stat(fsys, file)
+		it.Then(t).Should(it.True(errors.Is(err, fs.ErrNotExist)))
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		fsys := factory(t)
+		cfs, ok := fsys.(stream.CopyFS)
+		if !ok {
+			t.Skip("stream.CopyFS is not implemented")
+		}
+		if cfg.copyTarget == nil {
+			t.Skip("target of Copy is not defined, see WithCopyTarget")
+		}
+
+		it.Then(t).Must(it.Nil(writeFile(fsys, file, content)))
+		it.Then(t).Must(it.Nil(cfs.Copy(file, cfg.copyTarget(fsys, root+"b.txt"))))
+		it.Then(t).Must(it.Nil(cfs.Wait(root+"b.txt", 5*time.Second)))
+
+		buf, err := fs.ReadFile(fsys, root+"b.txt")
+		it.Then(t).Should(
+			it.Nil(err),
+			it.Equal(string(buf), content),
+		)
+	})
+
+	t.Run("Wait/Error/Timeout", func(t *testing.T) {
+		fsys := factory(t)
+		cfs, ok := fsys.(stream.CopyFS)
+		if !ok {
+			t.Skip("stream.CopyFS is not implemented")
+		}
+
+		err := cfs.Wait(file, 10*time.Millisecond)
+		it.Then(t).ShouldNot(it.Nil(err))
+	})
+}
+
+func writeFile(fsys stream.CreateFS[struct{}], path, content string) error {
+	fd, err := fsys.Create(path, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(fd, content); err != nil {
+		fd.Cancel()
+		return err
+	}
+
+	return fd.Close()
+}
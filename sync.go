@@ -0,0 +1,209 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+
+	"github.com/fogfish/opts"
+)
+
+// Sync configuration options
+type SyncOption = opts.Option[SyncOpts]
+
+// Sync configuration options
+type SyncOpts struct {
+	dryRun bool
+	delete bool
+}
+
+var (
+	// Report the changes without copying or removing files
+	WithDryRun = opts.ForName[SyncOpts, bool]("dryRun")
+
+	// Remove files from destination that do not exist at source.
+	// The destination file system must implement RemoveFS.
+	WithDeleteExtraneous = opts.ForName[SyncOpts, bool]("delete")
+)
+
+// Outcome of Sync, absolute paths of files
+type SyncReport struct {
+	Copied  []string
+	Skipped []string
+	Deleted []string
+}
+
+// Sync replicates files under the prefix (directory ending with `/`) from
+// source to destination file system. Only files missing at destination or
+// differing from the source (size, ETag if known by both file systems or
+// source modified after destination) are copied.
+func Sync[T any](src fs.FS, dst CreateFS[T], prefix string, opt ...SyncOption) (SyncReport, error) {
+	var report SyncReport
+
+	if err := RequireValidDir("sync", prefix); err != nil {
+		return report, err
+	}
+
+	var sopts SyncOpts
+	if err := opts.Apply(&sopts, opt); err != nil {
+		return report, err
+	}
+
+	var remover RemoveFS
+	if sopts.delete {
+		fsys, ok := dst.(RemoveFS)
+		if !ok {
+			return report, &fs.PathError{
+				Op:   "sync",
+				Path: prefix,
+				Err:  fmt.Errorf("%w: destination does not support remove", errors.ErrUnsupported),
+			}
+		}
+		remover = fsys
+	}
+
+	seen := map[string]struct{}{}
+	err := walkFiles(src, prefix, func(path string, d fs.DirEntry) error {
+		seen[path] = struct{}{}
+
+		si, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		di, err := fs.Stat(dst, path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		case !isSyncRequired(si, di):
+			report.Skipped = append(report.Skipped, path)
+			return nil
+		}
+
+		if !sopts.dryRun {
+			if err := CopyAcross(src, path, dst, path); err != nil {
+				return err
+			}
+		}
+		report.Copied = append(report.Copied, path)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if remover == nil {
+		return report, nil
+	}
+
+	err = walkFiles(dst, prefix, func(path string, d fs.DirEntry) error {
+		if _, has := seen[path]; has {
+			return nil
+		}
+
+		if !sopts.dryRun {
+			if err := remover.Remove(path); err != nil {
+				return err
+			}
+		}
+		report.Deleted = append(report.Deleted, path)
+		return nil
+	})
+
+	return report, err
+}
+
+// walks all files under the dir, the dir is missing at file system is empty.
+func walkFiles(fsys fs.FS, dir string, fn func(string, fs.DirEntry) error) error {
+	seq, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, d := range seq {
+		path := dir + d.Name()
+		if d.IsDir() {
+			err = walkFiles(fsys, path+"/", fn)
+		} else {
+			err = fn(path, d)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isSyncRequired(src, dst fs.FileInfo) bool {
+	if src.Size() != dst.Size() {
+		return true
+	}
+
+	if a, b := etagOf(src), etagOf(dst); a != "" && b != "" {
+		return a != b
+	}
+
+	return src.ModTime().After(dst.ModTime())
+}
+
+// ETag of the file, if file system metadata embeds SystemMetadata
+func etagOf(fi fs.FileInfo) string {
+	v := reflect.ValueOf(fi.Sys())
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+
+	f := v.FieldByName("ETag")
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+
+	return f.String()
+}
+
+// CopyAcross copies the file between file systems of different kinds
+// (e.g. download from S3 to local file system or upload vice versa).
+// The file is streamed from source to destination, the destination file
+// is cancelled if copying fails.
+func CopyAcross[T any](src fs.FS, srcPath string, dst CreateFS[T], dstPath string) error {
+	r, err := src.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dst.Create(dstPath, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Cancel()
+		return err
+	}
+
+	return w.Close()
+}
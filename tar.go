@@ -0,0 +1,132 @@
+//
+// Copyright (C) 2024 Dmitry Kolesnikov
+//
+// This file may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+// https://github.com/fogfish/stream
+//
+
+package stream
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// TarTo streams files under the directory as tar archive into the writer
+// (e.g. download of the prefix as archive). Entries are named by the path
+// relative to the directory, bodies are streamed object by object without
+// buffering. Objects are archived as stored, without decompression.
+func (fsys *FileSystem[T]) TarTo(path string, w io.Writer) error {
+	path = fsys.canonical(path)
+	if err := RequireValidDir("tar", path); err != nil {
+		return err
+	}
+
+	ctx, cancel := fsys.timeoutContext(fsys.ctx)
+	seq, err := openDir(fsys, path).readAll(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, entry := range seq {
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(fi.Name(), "/")
+		if err := fsys.tarFile(tw, path+name, name, fi); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return &fs.PathError{Op: "tar", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+func (fsys *FileSystem[T]) tarFile(tw *tar.Writer, path, name string, fi fs.FileInfo) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     fi.Size(),
+		Mode:     0644,
+		ModTime:  fi.ModTime(),
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return &fs.PathError{Op: "tar", Path: path, Err: err}
+	}
+
+	fd := newReader(fsys, path)
+	fd.raw = true
+
+	// the size is fixed by header, the object shall not change meanwhile
+	if _, err := io.Copy(tw, fd); err != nil {
+		fd.Close()
+		return &fs.PathError{Op: "tar", Path: path, Err: err}
+	}
+
+	// close verifies the integrity of object (e.g. WithChecksumValidation)
+	if err := fd.Close(); err != nil {
+		return &fs.PathError{Op: "tar", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// TarFrom restores files from tar archive into the directory. Entries are
+// created relative to the directory, bodies are streamed through the writer.
+// Directories and other non-regular entries are skipped. The upload of
+// the entry is cancelled if the archive fails mid-stream.
+func (fsys *FileSystem[T]) TarFrom(path string, r io.Reader) error {
+	path = fsys.canonical(path)
+	if err := RequireValidDir("untar", path); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &fs.PathError{Op: "untar", Path: path, Err: err}
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		file := fsys.canonical(path + strings.TrimPrefix(hdr.Name, "/"))
+		if err := RequireValidFile("untar", file); err != nil {
+			return err
+		}
+
+		if err := fsys.untarFile(tr, file); err != nil {
+			return err
+		}
+	}
+}
+
+func (fsys *FileSystem[T]) untarFile(tr *tar.Reader, path string) error {
+	fd := newWriter(fsys, path, nil)
+
+	if _, err := io.Copy(fd, tr); err != nil {
+		fd.Cancel()
+		if _, ok := err.(*fs.PathError); ok {
+			return err
+		}
+		return &fs.PathError{Op: "untar", Path: path, Err: err}
+	}
+
+	return fd.Close()
+}
@@ -10,8 +10,10 @@ package stream
 
 import (
 	"context"
+	"hash"
 	"io"
 	"io/fs"
+	"strings"
 	"time"
 
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
@@ -29,6 +31,11 @@ type Canceler interface {
 	Cancel() error
 }
 
+// Reopen the file for reading from the beginning.
+type Reopener interface {
+	Reopen() error
+}
+
 // File is a writable object
 type File interface {
 	Stat
@@ -37,6 +44,14 @@ type File interface {
 	Canceler
 }
 
+// MmapFile is read-only memory mapped view of the file, random access is
+// served from memory without copying the file through read system calls.
+type MmapFile interface {
+	io.ReaderAt
+	io.Closer
+	Len() int
+}
+
 // File System extension supporting writable files
 type CreateFS[T any] interface {
 	fs.FS
@@ -56,16 +71,79 @@ type CopyFS interface {
 	Wait(path string, timeout time.Duration) error
 }
 
+// File System extension supporting file renaming (moving)
+type RenameFS interface {
+	fs.FS
+	Rename(source, target string) error
+}
+
+// File System extension guaranteeing that created files are not visible
+// until Close succeeds, partially written files are never observable.
+type AtomicFS interface {
+	fs.FS
+	Atomic()
+}
+
 // well-known attributes controlled by S3 system
 type SystemMetadata struct {
-	CacheControl    string
-	ContentEncoding string
-	ContentLanguage string
-	ContentType     string
-	Expires         *time.Time
-	ETag            string
-	LastModified    *time.Time
-	StorageClass    string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	ContentType        string
+	Expires            *time.Time
+	ETag               string
+	LastModified       *time.Time
+	StorageClass       string
+}
+
+// ETagIsMD5 reports if ETag of the object is MD5 digest of its content,
+// the ETag of objects created by multipart upload is not.
+func (m SystemMetadata) ETagIsMD5() bool {
+	return m.ETag != "" && !IsMultipartETag(m.ETag)
+}
+
+// IsMultipartETag reports if ETag is produced by multipart upload, it has
+// the shape "<md5 of part digests>-<number of parts>".
+func IsMultipartETag(etag string) bool {
+	etag = strings.Trim(etag, "\"")
+
+	i := strings.LastIndexByte(etag, '-')
+	if i <= 0 || i == len(etag)-1 {
+		return false
+	}
+
+	for _, c := range etag[i+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Object written by WriteBatch
+type WriteItem[T any] struct {
+	Path string
+	Attr *T
+	Body io.Reader
+}
+
+// Object read by GetObject, the body must be closed by the caller
+type Object[T any] struct {
+	Info fs.FileInfo
+	Attr *T
+	Body io.ReadCloser
+}
+
+// Hash algorithm used by CreateHashing (e.g. sha256.New)
+type Hash func() hash.Hash
+
+// Incomplete multipart upload
+type MultipartUpload struct {
+	Path      string
+	UploadID  string
+	Initiated time.Time
 }
 
 // Well-known attribute for reading pre-signed Urls of S3 objects
@@ -73,20 +151,36 @@ type PreSignedUrl struct {
 	PreSignedUrl string
 }
 
+// Pre-signed POST policy for direct browser upload. The form is submitted
+// to URL with Fields followed by the file.
+type PostPolicy struct {
+	URL    string
+	Fields map[string]string
+}
+
 //-----------------------------------------------------------------------------
 
 type S3 interface {
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
 	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 }
 
 type S3Upload interface {
 	Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error)
 }
 
+type S3Waiter interface {
+	Wait(ctx context.Context, params *s3.HeadObjectInput, maxWaitDur time.Duration, optFns ...func(*s3.ObjectExistsWaiterOptions)) error
+}
+
 type S3Signer interface {
 	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)